@@ -1,10 +1,156 @@
+create table data_streams (
+    id uuid default gen_random_uuid() not null,
+
+    "nmi" varchar(10) not null,
+    "nmi_configuration" varchar(240) not null,
+    "register_id" varchar(10),
+    "nmi_suffix" varchar(2) not null,
+    "mdm_data_stream_identifier" varchar(2),
+    "meter_serial_number" varchar(12),
+    "uom" varchar(5) not null,
+    "interval_length" integer not null,
+    "next_scheduled_read_date" date,
+
+    constraint data_streams_pk primary key (id),
+    constraint data_streams_unique_stream unique ("nmi", "nmi_suffix")
+);
+
 create table meter_readings (
     id uuid default gen_random_uuid() not null,
 
     "nmi" varchar(10) not null,
+    "nmi_suffix" varchar(2) not null,
     "timestamp" timestamp not null,
     "consumption" numeric not null,
+    "uom" varchar(5) not null,
+    "original_uom" varchar(5) not null,
+    "quality_method" varchar(3) not null,
+    "reason_code" integer,
+    "reason_description" varchar(240),
+    "update_date_time" timestamp not null,
+    "msats_load_date_time" timestamp,
+    "file_id" uuid,
 
     constraint meter_readings_pk primary key (id),
-    constraint meter_readings_unique_consumption unique ("nmi", "timestamp")
+    constraint meter_readings_unique_consumption unique ("nmi", "nmi_suffix", "timestamp")
+);
+
+create table interval_readings (
+    id uuid default gen_random_uuid() not null,
+
+    "nmi" varchar(10) not null,
+    "nmi_suffix" varchar(2) not null,
+    "interval_start" timestamp not null,
+    "value" numeric not null,
+    "uom" varchar(5) not null,
+    "original_uom" varchar(5) not null,
+    "quality" varchar(3) not null,
+    "reason_code" integer,
+    "reason_description" varchar(240),
+    "update_date_time" timestamp not null,
+
+    constraint interval_readings_pk primary key (id),
+    constraint interval_readings_unique_value unique ("nmi", "nmi_suffix", "interval_start")
+);
+
+create table b2b_details (
+    id uuid default gen_random_uuid() not null,
+
+    "nmi" varchar(10) not null,
+    "nmi_suffix" varchar(2) not null,
+    "trans_code" varchar(1) not null,
+    "ret_service_order" varchar(15) not null,
+    "read_date_time" timestamp,
+    "index_read" varchar(15),
+
+    constraint b2b_details_pk primary key (id)
+);
+
+-- a B2B detail is identified by its service order and read date time, which can be null, so it is indexed as -infinity
+create unique index b2b_details_unique_order on b2b_details ("nmi", "nmi_suffix", "ret_service_order", coalesce("read_date_time", '-infinity'));
+
+create table file_headers (
+    id uuid default gen_random_uuid() not null,
+
+    "file_name" varchar(255) not null,
+    "version_header" varchar(5) not null,
+    "date_time" timestamp not null,
+    "from_participant" varchar(10) not null,
+    "to_participant" varchar(10) not null,
+
+    constraint file_headers_pk primary key (id)
+);
+
+create table accumulation_readings (
+    id uuid default gen_random_uuid() not null,
+
+    "nmi" varchar(10) not null,
+    "nmi_configuration" varchar(240) not null,
+    "register_id" varchar(10) not null,
+    "nmi_suffix" varchar(2) not null,
+    "mdm_data_stream_identifier" varchar(2),
+    "meter_serial_number" varchar(12) not null,
+    "direction_indicator" varchar(1) not null,
+    "previous_register_read" numeric not null,
+    "previous_register_read_date_time" timestamp not null,
+    "previous_quality_method" varchar(3) not null,
+    "previous_reason_code" integer,
+    "previous_reason_description" varchar(240),
+    "current_register_read" numeric not null,
+    "current_register_read_date_time" timestamp not null,
+    "current_quality_method" varchar(3) not null,
+    "current_reason_code" integer,
+    "current_reason_description" varchar(240),
+    "quantity" numeric not null,
+    "uom" varchar(5) not null,
+    "original_uom" varchar(5) not null,
+    "next_scheduled_read_date" date,
+    "update_date_time" timestamp not null,
+    "msats_load_date_time" timestamp,
+    "previous_trans_code" varchar(1),
+    "previous_ret_service_order" varchar(15),
+    "current_trans_code" varchar(1),
+    "current_ret_service_order" varchar(15),
+
+    constraint accumulation_readings_pk primary key (id),
+    constraint accumulation_readings_unique_read unique ("nmi", "nmi_suffix", "current_register_read_date_time")
+);
+
+alter table meter_readings add constraint meter_readings_file_fk foreign key ("file_id") references file_headers (id);
+create index meter_readings_file_id on meter_readings ("file_id");
+
+create table file_processing (
+    id uuid default gen_random_uuid() not null,
+
+    "file_name" varchar(255) not null,
+    "file_hash" varchar(64),
+    "version_header" varchar(5),
+    "header_date_time" timestamp,
+    "from_participant" varchar(10),
+    "to_participant" varchar(10),
+    "status" varchar(10) not null,
+    "received_at" timestamp not null,
+    "started_at" timestamp,
+    "finished_at" timestamp,
+    "parsed_count" integer not null,
+    "inserted_count" bigint not null,
+    "failed_count" integer not null,
+    "duplicate_of" uuid,
+
+    constraint file_processing_pk primary key (id),
+    constraint file_processing_valid_status check ("status" in ('received', 'processing', 'completed', 'partial', 'failed', 'skipped')),
+    constraint file_processing_duplicate_fk foreign key ("duplicate_of") references file_processing (id)
+);
+
+create index file_processing_file_hash on file_processing ("file_hash");
+
+create table file_processing_failures (
+    id uuid default gen_random_uuid() not null,
+
+    "file_processing_id" uuid not null,
+    "nmi" text not null,
+    "errors" text not null,
+
+    constraint file_processing_failures_pk primary key (id),
+    constraint file_processing_failures_file_fk foreign key ("file_processing_id") references file_processing (id)
 );
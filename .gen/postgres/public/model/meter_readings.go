@@ -9,12 +9,22 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"github.com/ts33/energy-reading/decimal"
 	"time"
 )
 
 type MeterReadings struct {
-	ID          uuid.UUID `sql:"primary_key"`
-	Nmi         string
-	Timestamp   time.Time
-	Consumption float64
+	ID                uuid.UUID `sql:"primary_key"`
+	Nmi               string
+	NmiSuffix         string
+	Timestamp         time.Time
+	Consumption       decimal.Decimal
+	Uom               string
+	OriginalUom       string
+	QualityMethod     string
+	ReasonCode        *int32
+	ReasonDescription *string
+	UpdateDateTime    time.Time
+	MsatsLoadDateTime *time.Time
+	FileID            *uuid.UUID
 }
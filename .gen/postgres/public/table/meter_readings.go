@@ -17,10 +17,19 @@ type meterReadingsTable struct {
 	postgres.Table
 
 	// Columns
-	ID          postgres.ColumnString
-	Nmi         postgres.ColumnString
-	Timestamp   postgres.ColumnTimestamp
-	Consumption postgres.ColumnFloat
+	ID                postgres.ColumnString
+	Nmi               postgres.ColumnString
+	NmiSuffix         postgres.ColumnString
+	Timestamp         postgres.ColumnTimestamp
+	Consumption       postgres.ColumnFloat
+	Uom               postgres.ColumnString
+	OriginalUom       postgres.ColumnString
+	QualityMethod     postgres.ColumnString
+	ReasonCode        postgres.ColumnInteger
+	ReasonDescription postgres.ColumnString
+	UpdateDateTime    postgres.ColumnTimestamp
+	MsatsLoadDateTime postgres.ColumnTimestamp
+	FileID            postgres.ColumnString
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -61,22 +70,40 @@ func newMeterReadingsTable(schemaName, tableName, alias string) *MeterReadingsTa
 
 func newMeterReadingsTableImpl(schemaName, tableName, alias string) meterReadingsTable {
 	var (
-		IDColumn          = postgres.StringColumn("id")
-		NmiColumn         = postgres.StringColumn("nmi")
-		TimestampColumn   = postgres.TimestampColumn("timestamp")
-		ConsumptionColumn = postgres.FloatColumn("consumption")
-		allColumns        = postgres.ColumnList{IDColumn, NmiColumn, TimestampColumn, ConsumptionColumn}
-		mutableColumns    = postgres.ColumnList{NmiColumn, TimestampColumn, ConsumptionColumn}
+		IDColumn                = postgres.StringColumn("id")
+		NmiColumn               = postgres.StringColumn("nmi")
+		NmiSuffixColumn         = postgres.StringColumn("nmi_suffix")
+		TimestampColumn         = postgres.TimestampColumn("timestamp")
+		ConsumptionColumn       = postgres.FloatColumn("consumption")
+		UomColumn               = postgres.StringColumn("uom")
+		OriginalUomColumn       = postgres.StringColumn("original_uom")
+		QualityMethodColumn     = postgres.StringColumn("quality_method")
+		ReasonCodeColumn        = postgres.IntegerColumn("reason_code")
+		ReasonDescriptionColumn = postgres.StringColumn("reason_description")
+		UpdateDateTimeColumn    = postgres.TimestampColumn("update_date_time")
+		MsatsLoadDateTimeColumn = postgres.TimestampColumn("msats_load_date_time")
+		FileIDColumn            = postgres.StringColumn("file_id")
+		allColumns              = postgres.ColumnList{IDColumn, NmiColumn, NmiSuffixColumn, TimestampColumn, ConsumptionColumn, UomColumn, OriginalUomColumn, QualityMethodColumn, ReasonCodeColumn, ReasonDescriptionColumn, UpdateDateTimeColumn, MsatsLoadDateTimeColumn, FileIDColumn}
+		mutableColumns          = postgres.ColumnList{NmiColumn, NmiSuffixColumn, TimestampColumn, ConsumptionColumn, UomColumn, OriginalUomColumn, QualityMethodColumn, ReasonCodeColumn, ReasonDescriptionColumn, UpdateDateTimeColumn, MsatsLoadDateTimeColumn, FileIDColumn}
 	)
 
 	return meterReadingsTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		ID:          IDColumn,
-		Nmi:         NmiColumn,
-		Timestamp:   TimestampColumn,
-		Consumption: ConsumptionColumn,
+		ID:                IDColumn,
+		Nmi:               NmiColumn,
+		NmiSuffix:         NmiSuffixColumn,
+		Timestamp:         TimestampColumn,
+		Consumption:       ConsumptionColumn,
+		Uom:               UomColumn,
+		OriginalUom:       OriginalUomColumn,
+		QualityMethod:     QualityMethodColumn,
+		ReasonCode:        ReasonCodeColumn,
+		ReasonDescription: ReasonDescriptionColumn,
+		UpdateDateTime:    UpdateDateTimeColumn,
+		MsatsLoadDateTime: MsatsLoadDateTimeColumn,
+		FileID:            FileIDColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
@@ -10,5 +10,12 @@ package table
 // UseSchema sets a new schema name for all generated table SQL builder types. It is recommended to invoke
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
+	AccumulationReadings = AccumulationReadings.FromSchema(schema)
+	B2bDetails = B2bDetails.FromSchema(schema)
+	DataStreams = DataStreams.FromSchema(schema)
+	FileHeaders = FileHeaders.FromSchema(schema)
+	FileProcessing = FileProcessing.FromSchema(schema)
+	FileProcessingFailures = FileProcessingFailures.FromSchema(schema)
+	IntervalReadings = IntervalReadings.FromSchema(schema)
 	MeterReadings = MeterReadings.FromSchema(schema)
 }
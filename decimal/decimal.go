@@ -0,0 +1,273 @@
+// Package decimal provides an exact decimal number for meter readings, so that values are carried
+// from an NMI file to the numeric columns of the datastore without floating point drift.
+package decimal
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxDigits is the number of significant digits that a Decimal can hold.
+const MaxDigits = 18
+
+// ErrOverflow is returned when the result of an operation needs more than MaxDigits significant digits.
+var ErrOverflow = errors.New("decimal: result has more than 18 significant digits")
+
+// RoundingMode decides how a Decimal is rounded when it has more decimal places than are kept.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest value, and away from zero when halfway between two values.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest value, and to the even value when halfway between two values.
+	RoundHalfEven
+	// RoundDown truncates the decimal places that are not kept.
+	RoundDown
+)
+
+// Decimal is the exact value coefficient × 10^exponent.
+// Decimals are always normalised, so that equal values have the same coefficient and exponent and can be compared with ==.
+type Decimal struct {
+	coefficient int64
+	exponent    int32
+}
+
+// Zero is the Decimal with the value 0.
+var Zero = Decimal{}
+
+// New creates the Decimal coefficient × 10^exponent.
+func New(coefficient int64, exponent int32) Decimal {
+	return normalise(coefficient, exponent)
+}
+
+// Parse parses a decimal number such as "-12.345". Exponents are not supported.
+// The errors returned wrap strconv.ErrSyntax or strconv.ErrRange, in the same way as the strconv functions.
+func Parse(s string) (Decimal, error) {
+	digits := s
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return Zero, fmt.Errorf("decimal.Parse: parsing %q: %w", s, strconv.ErrSyntax)
+	}
+
+	var coefficient int64
+	significant := 0
+	for _, c := range whole + fraction {
+		if c < '0' || c > '9' {
+			return Zero, fmt.Errorf("decimal.Parse: parsing %q: %w", s, strconv.ErrSyntax)
+		}
+		if significant > 0 || c != '0' {
+			significant++
+		}
+		if significant > MaxDigits {
+			return Zero, fmt.Errorf("decimal.Parse: parsing %q: %w", s, strconv.ErrRange)
+		}
+		coefficient = coefficient*10 + int64(c-'0')
+	}
+	if negative {
+		coefficient = -coefficient
+	}
+	return normalise(coefficient, -int32(len(fraction))), nil
+}
+
+// MustParse is like Parse, but panics if s cannot be parsed. It is intended for constants and tests.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Add returns d + other, or ErrOverflow if the result cannot be held exactly.
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	a, b, exponent, err := align(d, other)
+	if err != nil {
+		return Zero, err
+	}
+	// both coefficients have at most MaxDigits digits, so their sum cannot overflow an int64
+	sum := a + b
+	if sum > maxCoefficient || sum < -maxCoefficient {
+		return Zero, ErrOverflow
+	}
+	return normalise(sum, exponent), nil
+}
+
+// Sum adds up values, or returns ErrOverflow if the total cannot be held exactly.
+func Sum(values []Decimal) (Decimal, error) {
+	total := Zero
+	var err error
+	for _, value := range values {
+		total, err = total.Add(value)
+		if err != nil {
+			return Zero, err
+		}
+	}
+	return total, nil
+}
+
+// Shift returns d × 10^places, which is exact, such as when converting Wh into kWh.
+func (d Decimal) Shift(places int32) Decimal {
+	if d.coefficient == 0 {
+		return Zero
+	}
+	return Decimal{d.coefficient, d.exponent + places}
+}
+
+// Round returns d rounded to the given number of decimal places with the RoundingMode.
+func (d Decimal) Round(places int32, mode RoundingMode) Decimal {
+	if d.exponent >= -places {
+		return d
+	}
+	drop := -places - d.exponent
+	if drop > MaxDigits {
+		return Zero
+	}
+	divisor := pow10(drop)
+	quotient := d.coefficient / divisor
+	remainder := d.coefficient % divisor
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	sign := int64(1)
+	if d.coefficient < 0 {
+		sign = -1
+	}
+
+	roundAway := false
+	switch mode {
+	case RoundHalfUp:
+		roundAway = remainder*2 >= divisor
+	case RoundHalfEven:
+		roundAway = remainder*2 > divisor || (remainder*2 == divisor && quotient%2 != 0)
+	case RoundDown:
+		roundAway = false
+	}
+	if roundAway {
+		quotient += sign
+	}
+	return normalise(quotient, -places)
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than other.
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _, err := align(d, other)
+	if err != nil {
+		// the values are too far apart to align exactly, so comparing them approximately is enough
+		return cmp.Compare(d.Float64(), other.Float64())
+	}
+	return cmp.Compare(a, b)
+}
+
+// IsZero reports whether d is 0.
+func (d Decimal) IsZero() bool {
+	return d.coefficient == 0
+}
+
+// Float64 returns the nearest float64 to d, for when an approximate value is good enough.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String formats d without an exponent, such as "-12.345".
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.coefficient, 10)
+	sign := ""
+	if d.coefficient < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if d.exponent >= 0 {
+		if d.coefficient == 0 {
+			return "0"
+		}
+		return sign + digits + strings.Repeat("0", int(d.exponent))
+	}
+	places := int(-d.exponent)
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-places] + "." + digits[len(digits)-places:]
+}
+
+// Value implements driver.Valuer, so that a Decimal is written to a numeric column as its exact text.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner, so that a Decimal can be read from a numeric column.
+func (d *Decimal) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case string:
+		*d, err = Parse(v)
+	case []byte:
+		*d, err = Parse(string(v))
+	case int64:
+		*d = New(v, 0)
+	case float64:
+		*d, err = Parse(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("decimal: cannot scan %T", src)
+	}
+	return err
+}
+
+// maxCoefficient is the largest coefficient with MaxDigits digits.
+const maxCoefficient = 999_999_999_999_999_999
+
+// normalise removes the trailing zeros of the coefficient, so that equal values have the same representation.
+func normalise(coefficient int64, exponent int32) Decimal {
+	if coefficient == 0 {
+		return Zero
+	}
+	for coefficient%10 == 0 {
+		coefficient /= 10
+		exponent++
+	}
+	return Decimal{coefficient, exponent}
+}
+
+// align returns the coefficients of a and b at the smaller of their exponents.
+func align(a Decimal, b Decimal) (int64, int64, int32, error) {
+	if a.coefficient == 0 {
+		return 0, b.coefficient, b.exponent, nil
+	}
+	if b.coefficient == 0 {
+		return a.coefficient, 0, a.exponent, nil
+	}
+	if a.exponent < b.exponent {
+		bc, err := scaleUp(b.coefficient, b.exponent-a.exponent)
+		return a.coefficient, bc, a.exponent, err
+	}
+	ac, err := scaleUp(a.coefficient, a.exponent-b.exponent)
+	return ac, b.coefficient, b.exponent, err
+}
+
+// scaleUp returns coefficient × 10^places, or ErrOverflow if it needs more than MaxDigits digits.
+func scaleUp(coefficient int64, places int32) (int64, error) {
+	for ; places > 0; places-- {
+		if coefficient > maxCoefficient/10 || coefficient < -maxCoefficient/10 {
+			return 0, ErrOverflow
+		}
+		coefficient *= 10
+	}
+	return coefficient, nil
+}
+
+// pow10 returns 10^n for 0 <= n <= MaxDigits.
+func pow10(n int32) int64 {
+	result := int64(1)
+	for ; n > 0; n-- {
+		result *= 10
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package decimal_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/ts33/energy-reading/decimal"
+)
+
+type ParseTestCase struct {
+	Name   string
+	Input  string
+	String string
+	Err    error
+}
+
+func TestParse(t *testing.T) {
+	tests := []ParseTestCase{
+		{Name: "Happy Case - integer", Input: "12", String: "12", Err: nil},
+		{Name: "Happy Case - decimal places", Input: "0.461", String: "0.461", Err: nil},
+		{Name: "Happy Case - trailing zeros", Input: "1.500", String: "1.5", Err: nil},
+		{Name: "Happy Case - leading zeros", Input: "000020", String: "20", Err: nil},
+		{Name: "Happy Case - negative", Input: "-0.05", String: "-0.05", Err: nil},
+		{Name: "Happy Case - positive sign", Input: "+3.25", String: "3.25", Err: nil},
+		{Name: "Happy Case - no whole digits", Input: ".5", String: "0.5", Err: nil},
+		{Name: "Happy Case - zero", Input: "0.000", String: "0", Err: nil},
+		{Name: "Error Case - not a number", Input: "abc", Err: errors.New("decimal.Parse: parsing \"abc\": invalid syntax")},
+		{Name: "Error Case - empty", Input: "", Err: errors.New("decimal.Parse: parsing \"\": invalid syntax")},
+		{Name: "Error Case - exponent", Input: "1e3", Err: errors.New("decimal.Parse: parsing \"1e3\": invalid syntax")},
+		{Name: "Error Case - two decimal points", Input: "1.2.3", Err: errors.New("decimal.Parse: parsing \"1.2.3\": invalid syntax")},
+		{Name: "Error Case - too many digits", Input: "1234567890.1234567890", Err: errors.New("decimal.Parse: parsing \"1234567890.1234567890\": value out of range")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			d, err := decimal.Parse(tt.Input)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if d.String() != tt.String {
+				t.Errorf("Expected %v, got %v instead", tt.String, d.String())
+			}
+		})
+	}
+}
+
+func TestParseErrorsWrapStrconv(t *testing.T) {
+	_, err := decimal.Parse("abc")
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Expected %v to wrap strconv.ErrSyntax", err)
+	}
+	_, err = decimal.Parse("12345678901234567890")
+	if !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("Expected %v to wrap strconv.ErrRange", err)
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	// 0.1 cannot be represented by a float64, so adding it up drifts away from the exact total
+	values := make([]decimal.Decimal, 0, 10000)
+	floatSum := 0.0
+	for i := 0; i < 10000; i++ {
+		values = append(values, decimal.MustParse("0.1"))
+		floatSum += 0.1
+	}
+	sum, err := decimal.Sum(values)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if sum != decimal.MustParse("1000") {
+		t.Errorf("Expected 1000, got %v instead", sum)
+	}
+	if floatSum == 1000 {
+		t.Errorf("Expected the float64 sum to drift from 1000")
+	}
+
+	// a month of 30 minute interval values
+	values = values[:0]
+	for i := 0; i < 31*48; i++ {
+		values = append(values, decimal.MustParse("0.333"))
+	}
+	sum, err = decimal.Sum(values)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if sum.String() != "495.504" {
+		t.Errorf("Expected 495.504, got %v instead", sum)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	sum, err := decimal.MustParse("1.25").Add(decimal.MustParse("-0.05"))
+	if err != nil || sum != decimal.MustParse("1.2") {
+		t.Errorf("Expected 1.2, got %v, %v instead", sum, err)
+	}
+	sum, err = decimal.MustParse("999999999999999999").Add(decimal.MustParse("1"))
+	if !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("Expected err %v, got %v, %v instead", decimal.ErrOverflow, sum, err)
+	}
+	sum, err = decimal.MustParse("100000000000000000").Add(decimal.MustParse("0.1"))
+	if !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("Expected err %v, got %v, %v instead", decimal.ErrOverflow, sum, err)
+	}
+}
+
+func TestShift(t *testing.T) {
+	if s := decimal.MustParse("1234.5").Shift(-3).String(); s != "1.2345" {
+		t.Errorf("Expected Wh to kWh to be 1.2345, got %v instead", s)
+	}
+	if s := decimal.MustParse("0.0005").Shift(3).String(); s != "0.5" {
+		t.Errorf("Expected MWh to kWh to be 0.5, got %v instead", s)
+	}
+	if s := decimal.MustParse("12").Shift(3).String(); s != "12000" {
+		t.Errorf("Expected 12000, got %v instead", s)
+	}
+}
+
+type RoundTestCase struct {
+	Input  string
+	Places int32
+	Mode   decimal.RoundingMode
+	Want   string
+}
+
+func TestRound(t *testing.T) {
+	tests := []RoundTestCase{
+		{Input: "1.2345", Places: 3, Mode: decimal.RoundHalfUp, Want: "1.235"},
+		{Input: "-1.2345", Places: 3, Mode: decimal.RoundHalfUp, Want: "-1.235"},
+		{Input: "1.2344", Places: 3, Mode: decimal.RoundHalfUp, Want: "1.234"},
+		{Input: "1.2345", Places: 3, Mode: decimal.RoundHalfEven, Want: "1.234"},
+		{Input: "1.2355", Places: 3, Mode: decimal.RoundHalfEven, Want: "1.236"},
+		{Input: "1.23451", Places: 3, Mode: decimal.RoundHalfEven, Want: "1.235"},
+		{Input: "1.2349", Places: 3, Mode: decimal.RoundDown, Want: "1.234"},
+		{Input: "-1.2349", Places: 3, Mode: decimal.RoundDown, Want: "-1.234"},
+		{Input: "0.9995", Places: 3, Mode: decimal.RoundHalfUp, Want: "1"},
+		{Input: "1.5", Places: 3, Mode: decimal.RoundHalfUp, Want: "1.5"},
+		{Input: "0.0000000000000000001", Places: 0, Mode: decimal.RoundHalfUp, Want: "0"},
+		{Input: "125", Places: -1, Mode: decimal.RoundHalfUp, Want: "130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Input, func(t *testing.T) {
+			result := decimal.MustParse(tt.Input).Round(tt.Places, tt.Mode)
+			if result.String() != tt.Want {
+				t.Errorf("Expected %v, got %v instead", tt.Want, result)
+			}
+		})
+	}
+}
+
+func TestCmp(t *testing.T) {
+	if decimal.MustParse("1.50").Cmp(decimal.MustParse("1.5")) != 0 {
+		t.Errorf("Expected 1.50 to equal 1.5")
+	}
+	if decimal.MustParse("-2").Cmp(decimal.MustParse("1.5")) != -1 {
+		t.Errorf("Expected -2 to be less than 1.5")
+	}
+	if decimal.New(1, 30).Cmp(decimal.New(1, -30)) != 1 {
+		t.Errorf("Expected 1e30 to be greater than 1e-30")
+	}
+}
+
+func TestValueAndScan(t *testing.T) {
+	value, err := decimal.MustParse("31.444").Value()
+	if err != nil || value != "31.444" {
+		t.Errorf("Expected 31.444, got %v, %v instead", value, err)
+	}
+
+	sources := []any{"31.444", []byte("31.444"), 31.444}
+	for _, src := range sources {
+		var d decimal.Decimal
+		err := d.Scan(src)
+		if err != nil || d != decimal.MustParse("31.444") {
+			t.Errorf("Expected %v to scan into 31.444, got %v, %v instead", src, d, err)
+		}
+	}
+	var d decimal.Decimal
+	if err := d.Scan(int64(12)); err != nil || d != decimal.New(12, 0) {
+		t.Errorf("Expected 12, got %v, %v instead", d, err)
+	}
+	if err := d.Scan(nil); err == nil {
+		t.Errorf("Expected an error when scanning NULL")
+	}
+}
@@ -0,0 +1,370 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/nem12"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+// ErrDuplicateFile is returned when a file is skipped, as a file with the same content has already completed.
+var ErrDuplicateFile = errors.New("file has already been completed")
+
+// Outcome is what became of an NMI file that was streamed.
+type Outcome string
+
+const (
+	// OutcomeProcessed is a file that was read into its sink, which can still have NMI blocks that failed or did not reconcile.
+	OutcomeProcessed Outcome = "processed"
+	// OutcomeSkipped is a file that was not read into its sink, as a file with the same content has already completed.
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeFailed is a file that failed before it was completely read into its sink.
+	OutcomeFailed Outcome = "failed"
+)
+
+// StreamResult is the outcome of an NMI file that was streamed, along with the FileProcessing records that it refers to.
+type StreamResult struct {
+	Outcome Outcome
+	// FileProcessingID is the ID of the FileProcessing record of the file, when Options.FileProcessing is set.
+	FileProcessingID *uuid.UUID
+	// DuplicateOf is the ID of the FileProcessing record of the completed file that a skipped file has the same content as.
+	DuplicateOf *uuid.UUID
+}
+
+// newStreamResult returns the StreamResult of a file that was streamed with err.
+func newStreamResult(err error) StreamResult {
+	switch {
+	case errors.Is(err, ErrDuplicateFile):
+		return StreamResult{Outcome: OutcomeSkipped}
+	case err != nil:
+		return StreamResult{Outcome: OutcomeFailed}
+	default:
+		return StreamResult{Outcome: OutcomeProcessed}
+	}
+}
+
+// DefaultProgressInterval is how often the counts of a FileProcessing record are written while its file is streamed.
+const DefaultProgressInterval = 10 * time.Second
+
+// InsertCounter is implemented by a Sink that stores readings, so that the number of readings that it inserted or updated
+// can be recorded on the FileProcessing record of the file. Readings are the meter readings of a NEM12 file
+// and the accumulation readings of a NEM13 file, which are what the ParsedCount of the record counts.
+type InsertCounter interface {
+	InsertedRows() int64
+}
+
+// fileTracker records the lifecycle of an NMI file in its FileProcessing record, as the file is streamed.
+// A nil fileTracker records nothing, for when Options.FileProcessing is not set.
+type fileTracker struct {
+	repository repo.FileProcessingRepository
+	record     *model.FileProcessing
+	hash       hash.Hash
+	// hashed is set once the whole file has been hashed ahead of processing, so that it is not hashed again as it is read
+	hashed     bool
+	failedNmis []FailedNmi
+	// meterReadings is reconciled against the totals of the meter readings that were parsed, once the file has been streamed
+	meterReadings repo.MeterReadingRepository
+	totals        readingTotals
+	// the counts of the record are written once progressInterval has passed since they were last written at progressedAt
+	progressInterval time.Duration
+	progressedAt     time.Time
+}
+
+// startTracking creates the FileProcessing record of an NMI file that has been received, with the FileProcessing,
+// Reconcile and ProgressInterval of opts.
+func startTracking(ctx context.Context, opts Options) (*fileTracker, error) {
+	if opts.FileProcessing == nil {
+		return nil, nil
+	}
+	record := &model.FileProcessing{FileName: opts.FileName, Status: repo.FileStatusReceived, ReceivedAt: time.Now().UTC()}
+	err := opts.FileProcessing.CreateFileProcessing(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "Failed to create file processing record", err)
+	}
+	progressInterval := opts.ProgressInterval
+	if progressInterval <= 0 {
+		progressInterval = DefaultProgressInterval
+	}
+	return &fileTracker{
+		repository:       opts.FileProcessing,
+		record:           record,
+		hash:             sha256.New(),
+		meterReadings:    opts.Reconcile,
+		totals:           readingTotals{},
+		progressInterval: progressInterval,
+		progressedAt:     record.ReceivedAt,
+	}, nil
+}
+
+// checkDuplicate returns ErrDuplicateFile when a file with the same content as r has already completed,
+// along with the reader that the file is then streamed from, and a cleanup function that is always set.
+// The content is hashed ahead of processing, so that nothing of a duplicate is written into the sink,
+// and the hash is kept as the hash of the file, so that the file is not hashed again as it is processed.
+// An io.Seeker is rewound once it has been hashed, while any other reader, such as a HTTP body or stdin,
+// is spooled to a temporary file as it is hashed, which cleanup removes.
+// Two copies of the same file that are streamed at the same time are both processed, as neither has completed
+// when the other is checked, and nothing locks a hash or constrains the file_hash of file_processing to be unique.
+func (t *fileTracker) checkDuplicate(ctx context.Context, r io.Reader) (io.Reader, func(), error) {
+	cleanup := func() {}
+	if t == nil {
+		return r, cleanup, nil
+	}
+	source, cleanup, err := rewind(r, t.hash)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	t.hashed = true
+
+	fileHash := hex.EncodeToString(t.hash.Sum(nil))
+	records, err := t.repository.FileProcessingByHash(ctx, fileHash)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("%s: %w", "Failed to find file processing records", err)
+	}
+	for _, record := range records {
+		if record.Status == repo.FileStatusCompleted {
+			t.record.FileHash = &fileHash
+			duplicateOf := record.ID
+			t.record.DuplicateOf = &duplicateOf
+			return nil, cleanup, fmt.Errorf("%w with file processing record %s", ErrDuplicateFile, record.ID)
+		}
+	}
+	return source, cleanup, nil
+}
+
+// rewind reads r to its end into w, and returns a reader of the same content from the start.
+// An io.Seeker is seeked back to where it started, and any other reader is copied into a temporary file,
+// which is closed and removed by the returned cleanup function.
+func rewind(r io.Reader, w io.Writer) (io.Reader, func(), error) {
+	cleanup := func() {}
+	if seeker, ok := r.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		_, err = io.Copy(w, r)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		_, err = seeker.Seek(start, io.SeekStart)
+		return r, cleanup, err
+	}
+
+	spool, err := os.CreateTemp("", "nmi-file-*")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("%s: %w", "Failed to spool file", err)
+	}
+	cleanup = func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	_, err = io.Copy(io.MultiWriter(spool, w), r)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	_, err = spool.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("%s: %w", "Failed to spool file", err)
+	}
+	return spool, cleanup, nil
+}
+
+// reader returns r, with every byte that is read from it added to the hash of the file,
+// unless the file was already hashed ahead of processing.
+func (t *fileTracker) reader(r io.Reader) io.Reader {
+	if t == nil || t.hashed {
+		return r
+	}
+	return io.TeeReader(r, t.hash)
+}
+
+// processing records the metadata of the 100 record, once the file has started processing.
+func (t *fileTracker) processing(ctx context.Context, header *model.FileHeaders) error {
+	if t == nil {
+		return nil
+	}
+	startedAt := time.Now().UTC()
+	t.record.VersionHeader = &header.VersionHeader
+	t.record.HeaderDateTime = &header.DateTime
+	t.record.FromParticipant = &header.FromParticipant
+	t.record.ToParticipant = &header.ToParticipant
+	t.record.Status = repo.FileStatusProcessing
+	t.record.StartedAt = &startedAt
+	err := t.repository.UpdateFileProcessing(ctx, t.record)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "Failed to update file processing record", err)
+	}
+	return nil
+}
+
+// parsed counts the readings of an NMI block that was processed and written into the sink,
+// and totals its meter readings for reconciliation.
+func (t *fileTracker) parsed(results nem12.NmiResultsParams) error {
+	if t == nil {
+		return nil
+	}
+	t.record.ParsedCount += int32(len(results.MeterReadings) + len(results.AccumulationReadings))
+	if t.meterReadings == nil {
+		return nil
+	}
+	return t.totals.add(results.MeterReadings)
+}
+
+// failed counts an NMI block that failed processing.
+func (t *fileTracker) failed(failedNmi FailedNmi) {
+	if t != nil {
+		t.record.FailedCount++
+		t.failedNmis = append(t.failedNmis, failedNmi)
+	}
+}
+
+// progress writes the counts of the record once its progress interval has passed, so that a file that is still processing,
+// or whose run crashed, shows how far it got.
+func (t *fileTracker) progress(ctx context.Context, sink Sink) error {
+	if t == nil || time.Since(t.progressedAt) < t.progressInterval {
+		return nil
+	}
+	t.count(sink)
+	err := t.repository.UpdateFileProcessing(ctx, t.record)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "Failed to update file processing record", err)
+	}
+	t.progressedAt = time.Now().UTC()
+	return nil
+}
+
+// count sets the InsertedCount of the record, when sink counts the readings that it inserted.
+func (t *fileTracker) count(sink Sink) {
+	if counter, ok := sink.(InsertCounter); ok {
+		t.record.InsertedCount = counter.InsertedRows()
+	}
+}
+
+// reconcile compares the meter readings that were parsed with the meter readings that are now stored,
+// and records and counts every NMI that does not match as a failure of the file.
+func (t *fileTracker) reconcile(ctx context.Context) error {
+	if t.meterReadings == nil {
+		return nil
+	}
+	reconciliation, err := t.totals.reconcile(ctx, t.meterReadings)
+	if err != nil {
+		return err
+	}
+	mismatches := mismatchedNmis(reconciliation.Mismatches())
+	t.record.FailedCount += int32(len(mismatches))
+	t.failedNmis = append(t.failedNmis, mismatches...)
+	return nil
+}
+
+// replaceMismatches replaces the failures of the NMIs that an earlier reconciliation of the file found not to match
+// with the failed NMIs of the tracker, so that a file that is reconciled again does not record its mismatches twice.
+// The record is updated with the failures that it is left with, unless there were none to replace and none to record.
+func (t *fileTracker) replaceMismatches(ctx context.Context) error {
+	failures, err := t.repository.FileProcessingFailures(ctx, t.record.ID)
+	if err != nil {
+		return err
+	}
+	ids := []uuid.UUID{}
+	for _, failure := range failures {
+		if isMismatch(failure) {
+			ids = append(ids, failure.ID)
+		}
+	}
+	if len(ids) == 0 && len(t.failedNmis) == 0 {
+		return nil
+	}
+	deleted, err := t.repository.DeleteFileProcessingFailures(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	err = t.insertFailures(ctx)
+	if err != nil {
+		return err
+	}
+	t.record.FailedCount += int32(len(t.failedNmis)) - int32(deleted)
+	t.record.Status = repo.FileStatusCompleted
+	if t.record.FailedCount > 0 {
+		t.record.Status = repo.FileStatusPartial
+	}
+	return t.repository.UpdateFileProcessing(ctx, t.record)
+}
+
+// finish reconciles the file when it has been streamed, and records its final status, along with its failed NMI blocks
+// and the NMIs that do not match, before returning err. A file with either of them is partial.
+// The hash of the file is only recorded when the whole file was read, which is when err is nil,
+// or when the file was skipped as a duplicate.
+// The record is finished even when ctx has been cancelled, so that a cancelled file is recorded as failed.
+// The StreamResult of the file is returned along with err.
+func (t *fileTracker) finish(ctx context.Context, sink Sink, err error) (StreamResult, error) {
+	if t == nil {
+		return newStreamResult(err), err
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		err = t.reconcile(ctx)
+	}
+	finishedAt := time.Now().UTC()
+	t.record.FinishedAt = &finishedAt
+	switch {
+	case errors.Is(err, ErrDuplicateFile):
+		t.record.Status = repo.FileStatusSkipped
+	case err != nil:
+		t.record.Status = repo.FileStatusFailed
+	case len(t.failedNmis) > 0:
+		t.record.Status = repo.FileStatusPartial
+	default:
+		t.record.Status = repo.FileStatusCompleted
+	}
+	if err == nil {
+		fileHash := hex.EncodeToString(t.hash.Sum(nil))
+		t.record.FileHash = &fileHash
+	}
+	t.count(sink)
+
+	result := newStreamResult(err)
+	result.FileProcessingID = &t.record.ID
+	result.DuplicateOf = t.record.DuplicateOf
+
+	trackErr := t.insertFailures(ctx)
+	if trackErr == nil {
+		trackErr = t.repository.UpdateFileProcessing(ctx, t.record)
+	}
+	switch {
+	case trackErr == nil:
+		return result, err
+	case err == nil:
+		return result, fmt.Errorf("%s: %w", "Failed to update file processing record", trackErr)
+	default:
+		return result, errors.Join(err, fmt.Errorf("%s: %w", "Failed to update file processing record", trackErr))
+	}
+}
+
+// insertFailures stores the failed NMI blocks of the file, with the errors of a NMI block on separate lines.
+func (t *fileTracker) insertFailures(ctx context.Context) error {
+	if len(t.failedNmis) == 0 {
+		return nil
+	}
+	failures := make([]*model.FileProcessingFailures, 0, len(t.failedNmis))
+	for _, failedNmi := range t.failedNmis {
+		messages := make([]string, 0, len(failedNmi.Errs))
+		for _, err := range failedNmi.Errs {
+			messages = append(messages, err.Error())
+		}
+		failures = append(failures, &model.FileProcessingFailures{
+			FileProcessingID: t.record.ID,
+			Nmi:              failedNmi.Nmi,
+			Errors:           strings.Join(messages, "\n"),
+		})
+	}
+	return t.repository.InsertFileProcessingFailures(ctx, failures)
+}
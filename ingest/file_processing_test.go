@@ -0,0 +1,375 @@
+package ingest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/ingest"
+	"github.com/ts33/energy-reading/nem12"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+type FileProcessingTestCase struct {
+	Name     string
+	FileName string
+	Expected FileProcessingTestExpected
+}
+
+type FileProcessingTestExpected struct {
+	Status        string
+	FileHash      string
+	VersionHeader string
+	ParsedCount   int32
+	InsertedCount int64
+	FailedCount   int32
+	FailedNmis    []string
+	Err           error
+}
+
+func TestProcessFileRecordsFileProcessing(t *testing.T) {
+	tests := []FileProcessingTestCase{
+		{
+			Name:     "completed file",
+			FileName: "../test_files/sample.csv",
+			Expected: FileProcessingTestExpected{
+				Status:        repo.FileStatusCompleted,
+				FileHash:      "c2a86e0901eac190f1e28f59893c7b979c7ce618dcecd58c55505eb65d93ff48",
+				VersionHeader: "NEM12",
+				ParsedCount:   8,
+				InsertedCount: 8,
+			},
+		},
+		{
+			Name:     "file with failed NMI blocks",
+			FileName: "../test_files/sample_nmi.csv",
+			Expected: FileProcessingTestExpected{
+				Status:        repo.FileStatusPartial,
+				FileHash:      "7d81239e68674158b8bc829fa358609608e1d5352e9fe68b35814c6d94b812c1",
+				VersionHeader: "NEM12",
+				ParsedCount:   1,
+				InsertedCount: 1,
+				FailedCount:   3,
+				FailedNmis:    []string{"NEM120101", "NEM12010129", "NEM12O1011"},
+			},
+		},
+		{
+			Name:     "file that breaks the nesting rules",
+			FileName: "../test_files/sample_err_sequence.csv",
+			Expected: FileProcessingTestExpected{
+				Status:        repo.FileStatusFailed,
+				VersionHeader: "NEM12",
+				Err:           errors.New("../test_files/sample_err_sequence.csv:2: record 300: not expected after record 100, expected one of 200, 900"),
+			},
+		},
+		{
+			Name:     "file that does not exist",
+			FileName: "../test_files/does_not_exist.csv",
+			Expected: FileProcessingTestExpected{
+				Status: repo.FileStatusFailed,
+				Err:    errors.New("open ../test_files/does_not_exist.csv: no such file or directory"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			fileProcessing := repo.NewMemoryFileProcessingRepository()
+			sink := ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), repo.NewMemoryMeterReadingRepository(), 0)
+
+			_, err := ingest.ProcessFile(ctx, tt.FileName, ingest.Options{NumWorkers: 2, FileProcessing: fileProcessing}, sink)
+			if tt.Expected.Err != nil {
+				if err == nil || err.Error() != tt.Expected.Err.Error() {
+					t.Errorf("Expected %v, got %v instead", tt.Expected.Err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+
+			records, err := fileProcessing.FileProcessingByFileName(ctx, tt.FileName)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 file processing record, got %v instead", len(records))
+			}
+			record := records[0]
+			if record.Status != tt.Expected.Status {
+				t.Errorf("Expected status %v, got %v instead", tt.Expected.Status, record.Status)
+			}
+			if fileHash := stringValue(record.FileHash); fileHash != tt.Expected.FileHash {
+				t.Errorf("Expected file hash %v, got %v instead", tt.Expected.FileHash, fileHash)
+			}
+			if versionHeader := stringValue(record.VersionHeader); versionHeader != tt.Expected.VersionHeader {
+				t.Errorf("Expected version header %v, got %v instead", tt.Expected.VersionHeader, versionHeader)
+			}
+			if record.ParsedCount != tt.Expected.ParsedCount || record.InsertedCount != tt.Expected.InsertedCount || record.FailedCount != tt.Expected.FailedCount {
+				t.Errorf("Expected %v parsed, %v inserted and %v failed, got %v parsed, %v inserted and %v failed instead",
+					tt.Expected.ParsedCount, tt.Expected.InsertedCount, tt.Expected.FailedCount,
+					record.ParsedCount, record.InsertedCount, record.FailedCount)
+			}
+			if record.FinishedAt == nil || record.FinishedAt.Before(record.ReceivedAt) {
+				t.Errorf("Expected the file to be finished after it was received, got %+v instead", record)
+			}
+
+			failures, err := fileProcessing.FileProcessingFailures(ctx, record.ID)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			assertFailedNmis(t, tt.Expected.FailedNmis, failures)
+		})
+	}
+}
+
+func TestStreamRecordsProcessingWhileStreaming(t *testing.T) {
+	ctx := context.Background()
+	fileProcessing := repo.NewMemoryFileProcessingRepository()
+	sink := &statusSink{fileProcessing: fileProcessing, fileName: "../test_files/sample.csv"}
+
+	_, err := ingest.ProcessFile(ctx, "../test_files/sample.csv", ingest.Options{FileProcessing: fileProcessing}, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	expected := []string{repo.FileStatusProcessing, repo.FileStatusProcessing}
+	if reflect.DeepEqual(expected, sink.statuses) != true {
+		t.Errorf("Expected statuses %v while streaming, got %v instead", expected, sink.statuses)
+	}
+}
+
+// statusSink records the status of the FileProcessing record of its file, whenever it receives the results of an NMI block.
+type statusSink struct {
+	ingest.MemorySink
+	fileProcessing *repo.MemoryFileProcessingRepository
+	fileName       string
+	statuses       []string
+}
+
+func (s *statusSink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	records, err := s.fileProcessing.FileProcessingByFileName(ctx, s.fileName)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		s.statuses = append(s.statuses, record.Status)
+	}
+	return nil
+}
+
+func TestStreamRecordsCountsWhileStreaming(t *testing.T) {
+	ctx := context.Background()
+	fileProcessing := repo.NewMemoryFileProcessingRepository()
+	sink := &progressSink{
+		MeterReadingSink: ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), repo.NewMemoryMeterReadingRepository(), 4),
+		fileProcessing:   fileProcessing,
+		fileName:         "../test_files/sample.csv",
+	}
+
+	opts := ingest.Options{FileProcessing: fileProcessing, ProgressInterval: time.Nanosecond}
+	_, err := ingest.ProcessFile(ctx, "../test_files/sample.csv", opts, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	// every NMI block has been written into the sink, which has inserted its meter readings in batches of 4
+	if sink.record == nil || sink.record.Status != repo.FileStatusProcessing || sink.record.ParsedCount != 8 || sink.record.InsertedCount != 8 {
+		t.Errorf("Expected a processing record with 8 parsed and 8 inserted before the sink was flushed, got %+v instead", sink.record)
+	}
+}
+
+// progressSink records the FileProcessing record of its file when it is flushed, before the file has finished.
+type progressSink struct {
+	*ingest.MeterReadingSink
+	fileProcessing *repo.MemoryFileProcessingRepository
+	fileName       string
+	record         *model.FileProcessing
+}
+
+func (s *progressSink) Flush(ctx context.Context) error {
+	records, err := s.fileProcessing.FileProcessingByFileName(ctx, s.fileName)
+	if err != nil {
+		return err
+	}
+	s.record = records[0]
+	return s.MeterReadingSink.Flush(ctx)
+}
+
+func assertFailedNmis(t *testing.T, expected []string, failures []*model.FileProcessingFailures) {
+	t.Helper()
+	if len(expected) != len(failures) {
+		t.Fatalf("Expected %v failed NMIs, got %v instead", len(expected), len(failures))
+	}
+	for i := range expected {
+		if failures[i].Nmi != expected[i] || failures[i].Errors == "" {
+			t.Errorf("Expected failed NMI %v with its errors, got %+v instead", expected[i], failures[i])
+		}
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+type DuplicateFileTestCase struct {
+	Name      string
+	FileName  string
+	Force     bool
+	Seekable  bool
+	Status    string
+	Duplicate bool
+	Outcome   ingest.Outcome
+	Result    repo.UpsertResult
+}
+
+func TestStreamSkipsDuplicateFiles(t *testing.T) {
+	tests := []DuplicateFileTestCase{
+		{
+			Name:      "completed file",
+			FileName:  "../test_files/sample.csv",
+			Seekable:  true,
+			Status:    repo.FileStatusSkipped,
+			Duplicate: true,
+			Outcome:   ingest.OutcomeSkipped,
+		},
+		{
+			Name:     "completed file that is forced",
+			FileName: "../test_files/sample.csv",
+			Force:    true,
+			Seekable: true,
+			Status:   repo.FileStatusCompleted,
+			Outcome:  ingest.OutcomeProcessed,
+			Result:   repo.UpsertResult{Skipped: 8},
+		},
+		{
+			Name:     "partial file",
+			FileName: "../test_files/sample_nmi.csv",
+			Seekable: true,
+			Status:   repo.FileStatusPartial,
+			Outcome:  ingest.OutcomeProcessed,
+			Result:   repo.UpsertResult{Skipped: 1},
+		},
+		{
+			Name:      "completed file that cannot be rewound",
+			FileName:  "../test_files/sample.csv",
+			Status:    repo.FileStatusSkipped,
+			Duplicate: true,
+			Outcome:   ingest.OutcomeSkipped,
+		},
+		{
+			Name:     "partial file that cannot be rewound",
+			FileName: "../test_files/sample_nmi.csv",
+			Status:   repo.FileStatusPartial,
+			Outcome:  ingest.OutcomeProcessed,
+			Result:   repo.UpsertResult{Skipped: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			content, err := os.ReadFile(tt.FileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileProcessing := repo.NewMemoryFileProcessingRepository()
+			meterReadings := repo.NewMemoryMeterReadingRepository()
+			opts := ingest.Options{FileName: tt.FileName, FileProcessing: fileProcessing}
+
+			// the first delivery of the file is always processed
+			_, err = ingest.Stream(ctx, bytes.NewReader(content), opts, ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), meterReadings, 0))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+
+			var r io.Reader = bytes.NewReader(content)
+			if !tt.Seekable {
+				r = struct{ io.Reader }{r}
+			}
+			opts.Force = tt.Force
+			sink := ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), meterReadings, 0)
+			result, err := ingest.Stream(ctx, r, opts, sink)
+			if errors.Is(err, ingest.ErrDuplicateFile) != tt.Duplicate {
+				t.Fatalf("Expected a duplicate file to be %v, got %v instead", tt.Duplicate, err)
+			}
+			if !tt.Duplicate && err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if result.Outcome != tt.Outcome {
+				t.Errorf("Expected outcome %v, got %v instead", tt.Outcome, result.Outcome)
+			}
+			if sink.Result != tt.Result {
+				t.Errorf("Expected %+v, got %+v instead", tt.Result, sink.Result)
+			}
+
+			records, err := fileProcessing.FileProcessingByFileName(ctx, tt.FileName)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("Expected 2 file processing records, got %v instead", len(records))
+			}
+			original, delivery := records[0], records[1]
+			if delivery.Status != tt.Status {
+				t.Errorf("Expected status %v, got %v instead", tt.Status, delivery.Status)
+			}
+			if stringValue(delivery.FileHash) != stringValue(original.FileHash) {
+				t.Errorf("Expected file hash %v, got %v instead", stringValue(original.FileHash), stringValue(delivery.FileHash))
+			}
+			if tt.Duplicate != (delivery.DuplicateOf != nil && *delivery.DuplicateOf == original.ID) {
+				t.Errorf("Expected the delivery to be a duplicate of %v to be %v, got %v instead", original.ID, tt.Duplicate, delivery.DuplicateOf)
+			}
+			if result.FileProcessingID == nil || *result.FileProcessingID != delivery.ID {
+				t.Errorf("Expected the result to refer to %v, got %v instead", delivery.ID, result.FileProcessingID)
+			}
+			if tt.Duplicate != (result.DuplicateOf != nil && *result.DuplicateOf == original.ID) {
+				t.Errorf("Expected the result to be a duplicate of %v to be %v, got %v instead", original.ID, tt.Duplicate, result.DuplicateOf)
+			}
+		})
+	}
+}
+
+func TestStreamSkipsDuplicatesOfReadersThatCannotBeRewound(t *testing.T) {
+	ctx := context.Background()
+	content, err := os.ReadFile("../test_files/sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileProcessing := repo.NewMemoryFileProcessingRepository()
+	opts := ingest.Options{FileName: "../test_files/sample.csv", FileProcessing: fileProcessing}
+
+	// every delivery is read in parts, such as a HTTP body, and only the first is processed
+	outcomes := []ingest.Outcome{}
+	for i := 0; i < 3; i++ {
+		half := len(content) / 2
+		r := io.MultiReader(bytes.NewReader(content[:half]), bytes.NewReader(content[half:]))
+		sink := ingest.NewMemorySink()
+		result, err := ingest.Stream(ctx, r, opts, sink)
+		if err != nil && !errors.Is(err, ingest.ErrDuplicateFile) {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+		if result.Outcome == ingest.OutcomeSkipped && len(sink.Result.MeterReadings) != 0 {
+			t.Errorf("Expected nothing to be written for a skipped file, got %v meter readings instead", len(sink.Result.MeterReadings))
+		}
+		outcomes = append(outcomes, result.Outcome)
+	}
+	expected := []ingest.Outcome{ingest.OutcomeProcessed, ingest.OutcomeSkipped, ingest.OutcomeSkipped}
+	if reflect.DeepEqual(expected, outcomes) != true {
+		t.Errorf("Expected %v, got %v instead", expected, outcomes)
+	}
+
+	records, err := fileProcessing.FileProcessingByStatus(ctx, repo.FileStatusCompleted)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected 1 completed file processing record, got %v instead", len(records))
+	}
+}
@@ -0,0 +1,369 @@
+// Package ingest reads NMI files with the nem12 package, processes their NMI blocks with a pool of workers,
+// and writes the models of every NMI block into a Sink as they complete.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/nem12"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+// nmiWorkerParams contains the record that starts a NMI block (200 for NEM12, 250 for NEM13)
+// and the slice of records that belong to it.
+// LineNumbers holds the line number of every record of the NMI block, starting with the 200 or 250 record.
+type nmiWorkerParams struct {
+	NmiDataDetailsRecord string
+	NmiBlockRecords      []string
+	Nmi                  string
+	FileName             string
+	LineNumbers          []int
+}
+
+// FailedNmi contains a NMI whose block failed processing, along with every error that caused it to fail.
+type FailedNmi struct {
+	Nmi  string
+	Errs []error
+}
+
+// NmiFileResult contains everything that was processed from an NMI file, along with the NMIs that failed processing.
+type NmiFileResult struct {
+	Header               *model.FileHeaders
+	DataStreams          []*model.DataStreams
+	MeterReadings        []*model.MeterReadings
+	IntervalReadings     []*model.IntervalReadings
+	B2bDetails           []*model.B2bDetails
+	AccumulationReadings []*model.AccumulationReadings
+	FailedNmis           []FailedNmi
+}
+
+// Options configures how Process reads an NMI file.
+type Options struct {
+	// FileName is stored on the FileHeaders, and is used to locate errors. It can be left empty when the file has no name.
+	FileName string
+	// NumWorkers is the number of goroutines that NMI blocks are processed by. It defaults to 1.
+	NumWorkers int
+	// FileProcessing records the status of the file in a FileProcessing record as it is processed, when it is set.
+	// A file with the same SHA-256 hash as a file that has completed is then skipped, unless Force is set.
+	FileProcessing repo.FileProcessingRepository
+	// Force processes a file again, even when a file with the same content has completed.
+	Force bool
+	// Precision is the rule that meter reading values are converted into their canonical unit with.
+	// It defaults to nem12.DefaultPrecisionRule.
+	Precision *nem12.PrecisionRule
+	// Reconcile is queried once the file has been streamed, when FileProcessing is also set, to compare the row count
+	// and consumption total of the meter readings of every NMI with the meter readings that it now stores.
+	// A NMI that does not match is recorded as a failure of the file, which is then partial.
+	Reconcile repo.MeterReadingRepository
+	// ProgressInterval is how often the counts of the FileProcessing record are written while the file is streamed.
+	// It defaults to DefaultProgressInterval.
+	ProgressInterval time.Duration
+}
+
+// ProcessNmiFile opens an NMI file and processes it with Process.
+func ProcessNmiFile(fileName string, numWorkers int) (result NmiFileResult, err error) {
+	sink := NewMemorySink()
+	_, err = ProcessFile(context.Background(), fileName, Options{NumWorkers: numWorkers}, sink)
+	return sink.Result, err
+}
+
+// ProcessFile opens the NMI file of fileName, which is set as the FileName of opts, and streams it into sink with Stream.
+// A file that cannot be opened is recorded as failed when opts.FileProcessing is set.
+func ProcessFile(ctx context.Context, fileName string, opts Options, sink Sink) (StreamResult, error) {
+	opts.FileName = fileName
+	file, err := os.Open(fileName)
+	if err != nil {
+		tracker, trackErr := startTracking(ctx, opts)
+		if trackErr != nil {
+			return newStreamResult(err), errors.Join(err, trackErr)
+		}
+		return tracker.finish(ctx, sink, err)
+	}
+	defer file.Close()
+	return Stream(ctx, file, opts, sink)
+}
+
+// Process reads an NMI file from r with Stream, and returns everything that was processed from it, by using a MemorySink.
+func Process(ctx context.Context, r io.Reader, opts Options) (result NmiFileResult, err error) {
+	sink := NewMemorySink()
+	_, err = Stream(ctx, r, opts, sink)
+	return sink.Result, err
+}
+
+// Stream reads an NMI file from r, processes its NMI blocks with a pool of workers, and writes them into sink as they complete.
+// NEM12 and NEM13 files are both supported, based on the VersionHeader of the 100 record.
+// When ctx is cancelled, no more records are read and the workers are drained before ctx.Err() is returned.
+// A Read call of r that is blocked is not interrupted, so r should also be closed by the caller if it can block indefinitely.
+// The NMI blocks before a record that fails the whole file may already have been written into sink when an error is returned.
+// When opts.FileProcessing is set, the file is recorded as received before it is read, as processing once its 100 record
+// is parsed, and as completed, partial or failed once it has been streamed and reconciled against opts.Reconcile.
+// A file with the same content as a file that has completed is then recorded as skipped, and ErrDuplicateFile is returned
+// without anything being written into sink. The content is hashed once, before it is processed, so a reader that is not an io.Seeker,
+// such as a HTTP body, is first spooled to a temporary file. With opts.Force, it is instead hashed as it is processed.
+// Two copies of the same file that are streamed at the same time are not detected as duplicates of each other.
+// The StreamResult tells whether the file was processed, skipped or failed.
+func Stream(ctx context.Context, r io.Reader, opts Options, sink Sink) (StreamResult, error) {
+	if err := ctx.Err(); err != nil {
+		return newStreamResult(err), err
+	}
+	tracker, err := startTracking(ctx, opts)
+	if err != nil {
+		return newStreamResult(err), err
+	}
+	if !opts.Force {
+		var cleanup func()
+		r, cleanup, err = tracker.checkDuplicate(ctx, r)
+		defer cleanup()
+		if err != nil {
+			return tracker.finish(ctx, sink, err)
+		}
+	}
+	err = stream(ctx, tracker.reader(r), opts, sink, tracker)
+	return tracker.finish(ctx, sink, err)
+}
+
+// stream is Stream, with the lifecycle of the file recorded by tracker.
+func stream(ctx context.Context, r io.Reader, opts Options, sink Sink, tracker *fileTracker) (err error) {
+	fileName := opts.FileName
+	numWorkers := opts.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	// 1. Check that file starts with 100
+	reader := nem12.NewRecordReader(r)
+	if !reader.Next() {
+		if reader.Err() != nil {
+			return nem12.LocateFile(reader.Err(), fileName, nil)
+		}
+		return &nem12.ParseError{RecordLocation: nem12.RecordLocation{FileName: fileName, Field: nem12.WholeRecord}, Err: errors.New("unable to read first line")}
+	}
+	if reader.Indicator() != nem12.RecordIndicator_100 {
+		location := nem12.RecordLocation{FileName: fileName, Line: reader.LineNumber(), RecordIndicator: reader.Indicator(), Field: nem12.WholeRecord}
+		return &nem12.ValidationError{RecordLocation: location, Err: errors.New("first record is not a 100 record")}
+	}
+	header, err := nem12.ParseNmiHeader(reader.Record())
+	if err != nil {
+		return nem12.LocateFile(err, fileName, []int{reader.LineNumber()})
+	}
+	header.FileName = fileName
+	sequence := nem12.NewRecordSequence(header.VersionHeader)
+	err = sequence.Next(reader.Indicator(), reader.LineNumber())
+	if err != nil {
+		return nem12.LocateFile(err, fileName, nil)
+	}
+	err = tracker.processing(ctx, header)
+	if err != nil {
+		return err
+	}
+	err = sink.WriteHeader(ctx, header)
+	if err != nil {
+		return err
+	}
+
+	// 1.1 Choose the block processor for the version of the file, with the precision of the options
+	precision := nem12.DefaultPrecisionRule()
+	if opts.Precision != nil {
+		precision = *opts.Precision
+	}
+	blockIndicator := nem12.RecordIndicator_200
+	var processor nem12.NmiBlockProcessor = precision.ProcessNem12Block
+	if header.VersionHeader == nem12.VersionHeader_NEM13 {
+		blockIndicator = nem12.RecordIndicator_250
+		processor = precision.ProcessNem13Block
+	}
+
+	// 2.1 Create channels for work distribution - round workers to nearest multiple of 2
+	// good reference: https://stackoverflow.com/a/50261948/471538
+	// the channels are bounded, so that a slow sink holds back the workers, which in turn hold back the reading of the file
+	jobsChan := make(chan nmiWorkerParams, numWorkers)
+	resultsChan := make(chan nem12.NmiResultsParams, numWorkers)
+	failedChan := make(chan FailedNmi, numWorkers)
+	var wgWorker, wgOutput sync.WaitGroup
+	var muSink sync.Mutex
+	var sinkErr error
+	// a sink error stops the reading of the file and the workers, in the same way as cancelling ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	write := func(fn func() error) {
+		muSink.Lock()
+		defer muSink.Unlock()
+		// keep draining the channels after a sink error, so that the workers are not blocked
+		if sinkErr != nil || ctx.Err() != nil {
+			return
+		}
+		if err := fn(); err != nil {
+			sinkErr = err
+			cancel()
+		}
+	}
+
+	// 2.2 Start worker goroutines
+	for i := 0; i < numWorkers; i++ {
+		wgWorker.Add(1)
+		go nmiBlockWorker(ctx, jobsChan, processor, &wgWorker, resultsChan, failedChan)
+	}
+	wgOutput.Add(2)
+	// 2.3 Start goroutine that reads from results
+	go func() {
+		defer wgOutput.Done()
+		for readings := range resultsChan {
+			write(func() error {
+				err := sink.WriteResults(ctx, readings)
+				if err != nil {
+					return err
+				}
+				err = tracker.parsed(readings)
+				if err != nil {
+					return err
+				}
+				return tracker.progress(ctx, sink)
+			})
+		}
+	}()
+	// 2.4 Start goroutine that reads from failedChan
+	go func() {
+		defer wgOutput.Done()
+		for failedNmi := range failedChan {
+			write(func() error {
+				tracker.failed(failedNmi)
+				err := sink.WriteFailedNmi(ctx, failedNmi)
+				if err != nil {
+					return err
+				}
+				return tracker.progress(ctx, sink)
+			})
+		}
+	}()
+
+	// 3. loop through file and send nmiBlocks to the workers
+	err = dispatchNmiBlocks(ctx, reader, sequence, blockIndicator, fileName, jobsChan)
+
+	// 4.1 Explicitly close jobs channels as file reading is complete, or has stopped because of an error
+	// good reference: https://stackoverflow.com/a/59639259/471538
+	close(jobsChan)
+	// 4.2 Wait for all workers to finish
+	wgWorker.Wait()
+	// 4.3 close results and errors channel as all workers are done
+	close(resultsChan)
+	close(failedChan)
+	// 4.4 Wait for the two output go routines to finish
+	wgOutput.Wait()
+
+	// 5. Flush the sink once every NMI block has been written
+	if sinkErr != nil {
+		return sinkErr
+	}
+	if err != nil {
+		return err
+	}
+	return sink.Flush(ctx)
+}
+
+// newNmiFileResult creates a NmiFileResult with empty lists, so that a file without any NMI blocks has no nil lists.
+func newNmiFileResult() NmiFileResult {
+	return NmiFileResult{
+		DataStreams:          []*model.DataStreams{},
+		MeterReadings:        []*model.MeterReadings{},
+		IntervalReadings:     []*model.IntervalReadings{},
+		B2bDetails:           []*model.B2bDetails{},
+		AccumulationReadings: []*model.AccumulationReadings{},
+		FailedNmis:           []FailedNmi{},
+	}
+}
+
+// dispatchNmiBlocks reads the records that follow the 100 record, and sends every NMI block to jobsChan.
+// It stops at the first record that breaks the MDFF nesting rules, or when ctx is cancelled.
+func dispatchNmiBlocks(ctx context.Context, reader *nem12.RecordReader, sequence *nem12.RecordSequence, blockIndicator string, fileName string, jobsChan chan<- nmiWorkerParams) error {
+	var nmiBlockRecords []string
+	var nmiBlockLines []int
+	var nmiDataDetailsRecord string
+	var nem string
+
+	// send waits for a worker to be free, unless ctx is cancelled first
+	send := func() error {
+		select {
+		case jobsChan <- nmiWorkerParams{nmiDataDetailsRecord, nmiBlockRecords, nem, fileName, nmiBlockLines}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for reader.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := reader.Record()
+		// records that break the MDFF nesting rules fail the whole file
+		err := sequence.Next(reader.Indicator(), reader.LineNumber())
+		if err != nil {
+			return nem12.LocateFile(err, fileName, nil)
+		}
+
+		switch reader.Indicator() {
+		case blockIndicator:
+			// process the previous batch if available
+			if nmiDataDetailsRecord != "" {
+				if err := send(); err != nil {
+					return err
+				}
+				// reset blocks
+				nmiBlockRecords = []string{}
+			}
+			// capture the new NEM value, a record without one is failed by the block processor
+			splitLine := strings.Split(line, ",")
+			nmiDataDetailsRecord = line
+			nmiBlockLines = []int{reader.LineNumber()}
+			nem = ""
+			if len(splitLine) > 1 {
+				nem = splitLine[1]
+			}
+		case nem12.RecordIndicator_300, nem12.RecordIndicator_400, nem12.RecordIndicator_500, nem12.RecordIndicator_550:
+			nmiBlockRecords = append(nmiBlockRecords, line)
+			nmiBlockLines = append(nmiBlockLines, reader.LineNumber())
+		case nem12.RecordIndicator_900:
+			// process the last batch
+			if nmiDataDetailsRecord != "" {
+				if err := send(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Validate that the file was read completely, and the end of file indicator
+	if reader.Err() != nil {
+		return nem12.LocateFile(reader.Err(), fileName, nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return nem12.LocateFile(sequence.End(reader.LineNumber()), fileName, nil)
+}
+
+// nmiBlockWorker is a worker that receives nmiBlocks, processes them with the processor and sends the output to the results channel.
+// Once ctx is cancelled, the nmiBlocks that are left in jobsChan are drained without being processed.
+func nmiBlockWorker(ctx context.Context, jobsChan <-chan nmiWorkerParams, processor nem12.NmiBlockProcessor, wg *sync.WaitGroup, resultsChan chan<- nem12.NmiResultsParams, failedChan chan<- FailedNmi) {
+	defer wg.Done()
+	for j := range jobsChan {
+		if ctx.Err() != nil {
+			continue
+		}
+		results, err := processor(j.NmiDataDetailsRecord, j.NmiBlockRecords)
+		// push the errors to error chan if they exist, for reconciliation
+		if err != nil {
+			err = nem12.LocateFile(err, j.FileName, j.LineNumbers)
+			failedChan <- FailedNmi{Nmi: j.Nmi, Errs: nem12.SplitErrors(err)}
+		} else {
+			resultsChan <- results
+		}
+	}
+}
@@ -0,0 +1,702 @@
+package ingest_test
+
+import (
+	"errors"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	"github.com/ts33/energy-reading/ingest"
+	"github.com/ts33/energy-reading/nem12"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchmark with 100 records and 5 workers per pool
+func BenchmarkProcessNmiFile100(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = ingest.ProcessNmiFile("../test_files/sample_100.csv", 5)
+	}
+}
+
+// benchmark with 10000 records and 5 workers per pool
+func BenchmarkProcessNmiFile10000(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = ingest.ProcessNmiFile("../test_files/sample_10000.csv", 5)
+	}
+}
+
+// benchmark with 100000 records and 5 workers per pool
+func BenchmarkProcessNmiFile100000(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, _ = ingest.ProcessNmiFile("../test_files/sample_100000.csv", 5)
+	}
+}
+
+// updateDateTime and msatsLoadDateTime are the trailing date times of every NMI 300 record in the test files
+var updateDateTime = time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC)
+var msatsLoadDateTime = time.Date(2005, time.March, 10, 18, 22, 4, 0, time.UTC)
+
+type ProcessNmiFileTestCase struct {
+	Name                       string
+	ProcessNmiFileTestInput    ProcessNmiFileTestInput
+	ProcessNmiFileTestExpected ProcessNmiFileTestExpected
+}
+
+type ProcessNmiFileTestInput struct {
+	fileName   string
+	numWorkers int
+}
+
+type ProcessNmiFileTestExpected struct {
+	Header              *model.FileHeaders
+	MeterReadings       []*model.MeterReadings
+	NumIntervalReadings int
+	NumB2bDetails       int
+	NumDataStreams      int
+	FailedNmis          []string
+	Err                 error
+}
+
+func TestProcessNmiFile(t *testing.T) {
+	tests := []ProcessNmiFileTestCase{
+		{
+			Name: "Happy Case - Process File sample",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header: buildSampleHeader("../test_files/sample.csv"),
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("31.444"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("32.24"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 3, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("29.789"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 4, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("34.206"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:            "NEM1201010",
+						NmiSuffix:      "E2",
+						Timestamp:      time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:    decimal.MustParse("33.19"),
+						Uom:            "kWh",
+						OriginalUom:    "kWh",
+						QualityMethod:  "A",
+						UpdateDateTime: updateDateTime,
+					},
+					{
+						Nmi:            "NEM1201010",
+						NmiSuffix:      "E2",
+						Timestamp:      time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:    decimal.MustParse("31.811"),
+						Uom:            "kWh",
+						OriginalUom:    "kWh",
+						QualityMethod:  "A",
+						UpdateDateTime: updateDateTime,
+					},
+					{
+						Nmi:            "NEM1201010",
+						NmiSuffix:      "E2",
+						Timestamp:      time.Date(2005, time.March, 3, 0, 0, 0, 0, time.UTC),
+						Consumption:    decimal.MustParse("34.204"),
+						Uom:            "kWh",
+						OriginalUom:    "kWh",
+						QualityMethod:  "A",
+						UpdateDateTime: updateDateTime,
+					},
+					{
+						Nmi:            "NEM1201010",
+						NmiSuffix:      "E2",
+						Timestamp:      time.Date(2005, time.March, 4, 0, 0, 0, 0, time.UTC),
+						Consumption:    decimal.MustParse("31.354"),
+						Uom:            "kWh",
+						OriginalUom:    "kWh",
+						QualityMethod:  "A",
+						UpdateDateTime: updateDateTime,
+					},
+				},
+				NumIntervalReadings: 384,
+				NumB2bDetails:       2,
+				NumDataStreams:      2,
+				FailedNmis:          []string{},
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Error Case - file does not exist",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/does_not_exist.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("open ../test_files/does_not_exist.csv: no such file or directory"),
+			},
+		},
+		{
+			Name: "Error Case - empty file",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_empty.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("../test_files/sample_empty.csv: unable to read first line"),
+			},
+		},
+		{
+			Name: "Error Case - first record not 100",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_err_no_100.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("../test_files/sample_err_no_100.csv:1: record 600: first record is not a 100 record"),
+			},
+		},
+		{
+			Name: "Error Case - last record not 900",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_err_no_900.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header:        buildSampleHeader("../test_files/sample_err_no_900.csv"),
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("../test_files/sample_err_no_900.csv:8: record 999: not expected after record 500, expected one of 500, 200, 900"),
+			},
+		},
+		{
+			Name: "Error Case - file ends before 900",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_err_no_900_eof.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header:        buildSampleHeader("../test_files/sample_err_no_900_eof.csv"),
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("../test_files/sample_err_no_900_eof.csv:7: record 500: file ended after this record, expected one of 500, 200, 900"),
+			},
+		},
+		{
+			Name: "Error Case - 300 before 200",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_err_sequence.csv",
+				numWorkers: 1,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header:        buildSampleHeader("../test_files/sample_err_sequence.csv"),
+				MeterReadings: []*model.MeterReadings{},
+				FailedNmis:    []string{},
+				Err:           errors.New("../test_files/sample_err_sequence.csv:2: record 300: not expected after record 100, expected one of 200, 900"),
+			},
+		},
+		{
+			Name: "Happy Case - partial processing",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_err_partial.csv",
+				numWorkers: 2,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header: buildSampleHeader("../test_files/sample_err_partial.csv"),
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("31.444"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("32.24"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 3, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("29.789"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 4, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("34.206"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 192,
+				NumB2bDetails:       1,
+				NumDataStreams:      1,
+				FailedNmis: []string{
+					"NEM1201010",
+					"NEM1201011",
+					"NEM1201012",
+				},
+				Err: nil,
+			},
+		},
+		{
+			Name: "Happy Case - interval events",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_quality.csv",
+				numWorkers: 2,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header: buildSampleHeader("../test_files/sample_quality.csv"),
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("6"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "V",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("6"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 96,
+				NumB2bDetails:       1,
+				NumDataStreams:      1,
+				FailedNmis: []string{
+					"NEM1201010",
+				},
+				Err: nil,
+			},
+		},
+		{
+			Name: "Happy Case - mixed interval lengths",
+			ProcessNmiFileTestInput: ProcessNmiFileTestInput{
+				fileName:   "../test_files/sample_interval_lengths.csv",
+				numWorkers: 2,
+			},
+			ProcessNmiFileTestExpected: ProcessNmiFileTestExpected{
+				Header: buildSampleHeader("../test_files/sample_interval_lengths.csv"),
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201013",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("2.88"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201013",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("2.88"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201014",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("12"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201014",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("12"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 768,
+				NumB2bDetails:       2,
+				NumDataStreams:      2,
+				FailedNmis: []string{
+					"NEM1201015",
+					"NEM1201016",
+				},
+				Err: nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			fileResult, err := ingest.ProcessNmiFile(
+				tt.ProcessNmiFileTestInput.fileName,
+				tt.ProcessNmiFileTestInput.numWorkers,
+			)
+
+			// assert that the header is parsed
+			if reflect.DeepEqual(tt.ProcessNmiFileTestExpected.Header, fileResult.Header) != true {
+				t.Errorf("Expected header %+v, got %+v instead", tt.ProcessNmiFileTestExpected.Header, fileResult.Header)
+			}
+			result := fileResult.MeterReadings
+			failedNmis := fileResult.FailedNmis
+
+			// assert that number of failed NMI blocks are equal
+			if len(failedNmis) != len(tt.ProcessNmiFileTestExpected.FailedNmis) {
+				t.Errorf("Expected %+v number of failed NMIs, got %+v number of failed NMIs instead", len(tt.ProcessNmiFileTestExpected.FailedNmis), len(failedNmis))
+			}
+			sort.Slice(failedNmis, func(i, j int) bool {
+				return failedNmis[i].Nmi < failedNmis[j].Nmi
+			})
+			if len(failedNmis) > 0 {
+				for i, failed := range failedNmis {
+					if failed.Nmi != tt.ProcessNmiFileTestExpected.FailedNmis[i] {
+						t.Errorf("Expected failed NMI %v, got %v instead", tt.ProcessNmiFileTestExpected.FailedNmis[i], failed.Nmi)
+					}
+				}
+			}
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.ProcessNmiFileTestExpected.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				}
+				if tt.ProcessNmiFileTestExpected.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.ProcessNmiFileTestExpected.Err, err)
+				}
+			}
+
+			// assert result is correct
+			if len(result) != len(tt.ProcessNmiFileTestExpected.MeterReadings) {
+				t.Errorf("Expected %+v number of readings, got %+v number of readings instead", len(tt.ProcessNmiFileTestExpected.MeterReadings), len(result))
+			}
+			if len(fileResult.IntervalReadings) != tt.ProcessNmiFileTestExpected.NumIntervalReadings {
+				t.Errorf("Expected %+v number of interval readings, got %+v number of interval readings instead", tt.ProcessNmiFileTestExpected.NumIntervalReadings, len(fileResult.IntervalReadings))
+			}
+			if len(fileResult.B2bDetails) != tt.ProcessNmiFileTestExpected.NumB2bDetails {
+				t.Errorf("Expected %+v number of b2b details, got %+v number of b2b details instead", tt.ProcessNmiFileTestExpected.NumB2bDetails, len(fileResult.B2bDetails))
+			}
+			if len(fileResult.DataStreams) != tt.ProcessNmiFileTestExpected.NumDataStreams {
+				t.Errorf("Expected %+v number of data streams, got %+v number of data streams instead", tt.ProcessNmiFileTestExpected.NumDataStreams, len(fileResult.DataStreams))
+			}
+			// sort the results so that we can compare it with the expected output
+			sort.Slice(result, func(i, j int) bool {
+				if result[i].Nmi == result[j].Nmi {
+					return result[i].Timestamp.Before(result[j].Timestamp)
+				}
+				return result[i].Nmi < result[j].Nmi
+			})
+			for i, meterReading := range result {
+				if reflect.DeepEqual(tt.ProcessNmiFileTestExpected.MeterReadings[i], meterReading) != true {
+					t.Errorf("Expected %+v, got %+v instead", tt.ProcessNmiFileTestExpected.MeterReadings[i], meterReading)
+				}
+			}
+		})
+	}
+}
+
+// buildSampleHeader creates the FileHeaders that every NEM12 test file is expected to be parsed into.
+func buildSampleHeader(fileName string) *model.FileHeaders {
+	return &model.FileHeaders{
+		FileName:        fileName,
+		VersionHeader:   "NEM12",
+		DateTime:        time.Date(2005, time.June, 8, 11, 49, 0, 0, time.UTC),
+		FromParticipant: "UNITEDDP",
+		ToParticipant:   "NEMMCO",
+	}
+}
+
+func TestProcessNmiFileFailureReport(t *testing.T) {
+	expected := []string{
+		"../test_files/sample_err_multiple.csv:4: record 300 field 2: Failed to parse consumption value to decimal: decimal.Parse: parsing \"abc\": invalid syntax",
+		"../test_files/sample_err_multiple.csv:5: record 300 field 1: Failed to parse time value: parsing time \"2005033\" as \"20060102\": cannot parse \"3\" as \"02\"",
+	}
+
+	result, err := ingest.ProcessNmiFile("../test_files/sample_err_multiple.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(result.FailedNmis) != 1 {
+		t.Fatalf("Expected 1 failed NMI, got %v instead", result.FailedNmis)
+	}
+	failed := result.FailedNmis[0]
+	if failed.Nmi != "NEM1201009" {
+		t.Errorf("Expected failed NMI NEM1201009, got %v instead", failed.Nmi)
+	}
+	if len(failed.Errs) != len(expected) {
+		t.Fatalf("Expected %+v number of errors, got %+v number of errors instead", len(expected), len(failed.Errs))
+	}
+	for i, err := range failed.Errs {
+		if err.Error() != expected[i] {
+			t.Errorf("Expected err %v, got %v instead", expected[i], err)
+		}
+	}
+
+	// the errors carry their location, and wrap their cause
+	var parseErr *nem12.ParseError
+	if !errors.As(failed.Errs[0], &parseErr) {
+		t.Fatalf("Expected a ParseError, got %T instead", failed.Errs[0])
+	}
+	location := nem12.RecordLocation{FileName: "../test_files/sample_err_multiple.csv", Line: 4, RecordIndicator: "300", Field: 2, Record: 2}
+	if parseErr.RecordLocation != location {
+		t.Errorf("Expected location %+v, got %+v instead", location, parseErr.RecordLocation)
+	}
+	if !errors.Is(failed.Errs[0], strconv.ErrSyntax) {
+		t.Errorf("Expected %v to wrap strconv.ErrSyntax", failed.Errs[0])
+	}
+	var timeErr *time.ParseError
+	if !errors.As(failed.Errs[1], &timeErr) {
+		t.Errorf("Expected %v to wrap a time.ParseError", failed.Errs[1])
+	}
+}
+
+func TestProcessNmiFileValidationError(t *testing.T) {
+	_, err := ingest.ProcessNmiFile("../test_files/sample_err_sequence.csv", 1)
+
+	var validationErr *nem12.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v instead", err)
+	}
+	location := nem12.RecordLocation{FileName: "../test_files/sample_err_sequence.csv", Line: 2, RecordIndicator: "300", Field: nem12.WholeRecord}
+	if validationErr.RecordLocation != location {
+		t.Errorf("Expected location %+v, got %+v instead", location, validationErr.RecordLocation)
+	}
+}
+
+func TestProcessNmiFileFailedNmis(t *testing.T) {
+	expected := []ingest.FailedNmi{
+		{Nmi: "NEM120101", Errs: []error{errors.New("../test_files/sample_nmi.csv:4: record 200 field 1: nmi NEM120101 does not have 10 characters")}},
+		{Nmi: "NEM12010129", Errs: []error{errors.New("../test_files/sample_nmi.csv:8: record 200 field 1: nmi NEM12010129 has checksum 9, expected 0")}},
+		{Nmi: "NEM12O1011", Errs: []error{errors.New("../test_files/sample_nmi.csv:6: record 200 field 1: nmi NEM12O1011 contains the letter O")}},
+	}
+
+	result, err := ingest.ProcessNmiFile("../test_files/sample_nmi.csv", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(result.MeterReadings) != 1 || result.MeterReadings[0].Nmi != "NEM1201009" {
+		t.Errorf("Expected 1 meter reading for NEM1201009, got %+v instead", result.MeterReadings)
+	}
+
+	failedNmis := result.FailedNmis
+	if len(failedNmis) != len(expected) {
+		t.Fatalf("Expected %+v number of failed NMIs, got %+v number of failed NMIs instead", len(expected), len(failedNmis))
+	}
+	sort.Slice(failedNmis, func(i, j int) bool {
+		return failedNmis[i].Nmi < failedNmis[j].Nmi
+	})
+	for i, failed := range failedNmis {
+		if failed.Nmi != expected[i].Nmi || len(failed.Errs) != 1 || failed.Errs[0].Error() != expected[i].Errs[0].Error() {
+			t.Errorf("Expected failed NMI %v, got %v instead", expected[i], failed)
+		}
+	}
+}
+
+func TestProcessNmiFileNem13(t *testing.T) {
+	reasonCode := int32(77)
+	mdmDataStreamIdentifier := "11"
+	nextScheduledReadDate := time.Date(2004, time.May, 9, 0, 0, 0, 0, time.UTC)
+	msatsLoadDateTime := time.Date(2004, time.February, 3, 0, 1, 30, 0, time.UTC)
+	previousTransCode := "N"
+	currentTransCode := "A"
+
+	expected := []*model.AccumulationReadings{
+		{
+			Nmi:                          "NEM1301001",
+			NmiConfiguration:             "11",
+			RegisterID:                   "01",
+			NmiSuffix:                    "11",
+			MdmDataStreamIdentifier:      &mdmDataStreamIdentifier,
+			MeterSerialNumber:            "METSER123",
+			DirectionIndicator:           "E",
+			PreviousRegisterRead:         decimal.MustParse("20"),
+			PreviousRegisterReadDateTime: time.Date(2003, time.October, 1, 10, 32, 30, 0, time.UTC),
+			PreviousQualityMethod:        "A",
+			CurrentRegisterRead:          decimal.MustParse("10"),
+			CurrentRegisterReadDateTime:  time.Date(2004, time.February, 1, 10, 0, 30, 0, time.UTC),
+			CurrentQualityMethod:         "E64",
+			CurrentReasonCode:            &reasonCode,
+			Quantity:                     decimal.MustParse("343.5"),
+			Uom:                          "kWh",
+			OriginalUom:                  "kWh",
+			NextScheduledReadDate:        &nextScheduledReadDate,
+			UpdateDateTime:               time.Date(2004, time.February, 2, 12, 50, 10, 0, time.UTC),
+			MsatsLoadDateTime:            &msatsLoadDateTime,
+			PreviousTransCode:            &previousTransCode,
+			CurrentTransCode:             &currentTransCode,
+		},
+		{
+			Nmi:                          "NEM1301002",
+			NmiConfiguration:             "11",
+			RegisterID:                   "01",
+			NmiSuffix:                    "11",
+			MeterSerialNumber:            "METSER124",
+			DirectionIndicator:           "E",
+			PreviousRegisterRead:         decimal.MustParse("1000.5"),
+			PreviousRegisterReadDateTime: time.Date(2003, time.October, 1, 10, 32, 30, 0, time.UTC),
+			PreviousQualityMethod:        "A",
+			CurrentRegisterRead:          decimal.MustParse("1345.25"),
+			CurrentRegisterReadDateTime:  time.Date(2004, time.February, 1, 10, 0, 30, 0, time.UTC),
+			CurrentQualityMethod:         "A",
+			Quantity:                     decimal.MustParse("344.75"),
+			Uom:                          "kWh",
+			OriginalUom:                  "kWh",
+			UpdateDateTime:               time.Date(2004, time.February, 2, 12, 50, 10, 0, time.UTC),
+		},
+	}
+
+	result, err := ingest.ProcessNmiFile("../test_files/sample_nem13.csv", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if result.Header.VersionHeader != "NEM13" {
+		t.Errorf("Expected version header NEM13, got %v instead", result.Header.VersionHeader)
+	}
+	if len(result.MeterReadings) != 0 {
+		t.Errorf("Expected 0 number of readings, got %+v number of readings instead", len(result.MeterReadings))
+	}
+	if len(result.FailedNmis) != 1 || result.FailedNmis[0].Nmi != "NEM1301003" {
+		t.Errorf("Expected failed NMIs [NEM1301003], got %v instead", result.FailedNmis)
+	}
+
+	if len(result.AccumulationReadings) != len(expected) {
+		t.Fatalf("Expected %+v number of accumulation readings, got %+v number of accumulation readings instead", len(expected), len(result.AccumulationReadings))
+	}
+	// sort the results so that we can compare it with the expected output
+	if result.AccumulationReadings[0].Nmi > result.AccumulationReadings[1].Nmi {
+		result.AccumulationReadings[0], result.AccumulationReadings[1] = result.AccumulationReadings[1], result.AccumulationReadings[0]
+	}
+	for i, accumulationReading := range result.AccumulationReadings {
+		if reflect.DeepEqual(expected[i], accumulationReading) != true {
+			t.Errorf("Expected %+v, got %+v instead", expected[i], accumulationReading)
+		}
+	}
+}
+
+func TestProcessNmiFileWindowsFormat(t *testing.T) {
+	// sample_windows.csv is sample.csv with a byte order mark, CRLF line endings and blank lines
+	expected, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	result, err := ingest.ProcessNmiFile("../test_files/sample_windows.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	if len(result.FailedNmis) != 0 {
+		t.Errorf("Expected no failed NMIs, got %v instead", result.FailedNmis)
+	}
+	if len(result.IntervalReadings) != len(expected.IntervalReadings) {
+		t.Errorf("Expected %+v number of interval readings, got %+v number of interval readings instead", len(expected.IntervalReadings), len(result.IntervalReadings))
+	}
+	if len(result.MeterReadings) != len(expected.MeterReadings) {
+		t.Fatalf("Expected %+v number of readings, got %+v number of readings instead", len(expected.MeterReadings), len(result.MeterReadings))
+	}
+	sortMeterReadings(expected.MeterReadings)
+	sortMeterReadings(result.MeterReadings)
+	for i, meterReading := range result.MeterReadings {
+		if reflect.DeepEqual(expected.MeterReadings[i], meterReading) != true {
+			t.Errorf("Expected %+v, got %+v instead", expected.MeterReadings[i], meterReading)
+		}
+	}
+}
+
+func TestProcessNmiFileShortLine(t *testing.T) {
+	_, err := ingest.ProcessNmiFile("../test_files/sample_err_short_line.csv", 1)
+	expected := "../test_files/sample_err_short_line.csv:3: \"30\" does not start with a record indicator"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected err %v, got %v instead", expected, err)
+	}
+}
+
+func sortMeterReadings(meterReadings []*model.MeterReadings) {
+	sort.Slice(meterReadings, func(i, j int) bool {
+		if meterReadings[i].Nmi == meterReadings[j].Nmi {
+			return meterReadings[i].Timestamp.Before(meterReadings[j].Timestamp)
+		}
+		return meterReadings[i].Nmi < meterReadings[j].Nmi
+	})
+}
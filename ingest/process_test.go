@@ -0,0 +1,190 @@
+package ingest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ts33/energy-reading/decimal"
+	"github.com/ts33/energy-reading/ingest"
+	"github.com/ts33/energy-reading/nem12"
+)
+
+func TestProcessReader(t *testing.T) {
+	expected, err := ingest.ProcessNmiFile("../test_files/sample_100.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	content, err := os.ReadFile("../test_files/sample_100.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ingest.Process(context.Background(), bytes.NewReader(content), ingest.Options{NumWorkers: 4})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if result.Header.FileName != "" {
+		t.Errorf("Expected no file name, got %v instead", result.Header.FileName)
+	}
+	sortMeterReadings(expected.MeterReadings)
+	sortMeterReadings(result.MeterReadings)
+	if reflect.DeepEqual(expected.MeterReadings, result.MeterReadings) != true {
+		t.Errorf("Expected the same meter readings as ProcessNmiFile, got %+v instead", result.MeterReadings)
+	}
+	if len(result.IntervalReadings) != len(expected.IntervalReadings) {
+		t.Errorf("Expected %v interval readings, got %v instead", len(expected.IntervalReadings), len(result.IntervalReadings))
+	}
+}
+
+func TestProcessDefaultsToOneWorker(t *testing.T) {
+	file, err := os.Open("../test_files/sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	result, err := ingest.Process(context.Background(), file, ingest.Options{FileName: "sample.csv"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if result.Header.FileName != "sample.csv" {
+		t.Errorf("Expected file name sample.csv, got %v instead", result.Header.FileName)
+	}
+	if len(result.MeterReadings) == 0 {
+		t.Errorf("Expected meter readings, got none instead")
+	}
+}
+
+func TestProcessCancelledBeforeReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ingest.Process(ctx, &failingReader{}, ingest.Options{NumWorkers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected err %v, got %v instead", context.Canceled, err)
+	}
+}
+
+func TestProcessCancelledWhileReading(t *testing.T) {
+	content, err := os.ReadFile("../test_files/sample_100.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// cancel once a quarter of the file has been read
+	reader := &cancellingReader{Reader: bytes.NewReader(content), cancelAfter: len(content) / 4, cancel: cancel}
+
+	result, err := ingest.Process(ctx, reader, ingest.Options{NumWorkers: 4})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected err %v, got %v instead", context.Canceled, err)
+	}
+	if reader.read >= len(content) {
+		t.Errorf("Expected reading to stop before the end of the file")
+	}
+	if len(result.MeterReadings) == 0 {
+		t.Errorf("Expected the meter readings processed before cancelling, got none instead")
+	}
+	assertGoroutinesStopped(t, before)
+}
+
+func TestProcessDrainsWorkersOnError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		_, err := ingest.ProcessNmiFile("../test_files/sample_err_sequence.csv", 8)
+		if err == nil {
+			t.Fatalf("Expected an error, got no error instead")
+		}
+	}
+	assertGoroutinesStopped(t, before)
+}
+
+// assertGoroutinesStopped waits for the number of goroutines to return to before,
+// as a goroutine can still be exiting after it has called wg.Done.
+func assertGoroutinesStopped(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Errorf("Expected %v goroutines, got %v instead", before, runtime.NumGoroutine())
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// cancellingReader reads in small chunks, and cancels its context once cancelAfter bytes have been read.
+type cancellingReader struct {
+	io.Reader
+	cancelAfter int
+	cancel      context.CancelFunc
+	read        int
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	if len(p) > 512 {
+		p = p[:512]
+	}
+	n, err := r.Reader.Read(p)
+	r.read += n
+	if r.read >= r.cancelAfter {
+		r.cancel()
+	}
+	return n, err
+}
+
+// failingReader fails the test run if Process reads from it.
+type failingReader struct{}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	panic("Expected the reader not to be read")
+}
+
+type PrecisionTestCase struct {
+	Name        string
+	Precision   *nem12.PrecisionRule
+	Consumption decimal.Decimal
+}
+
+func TestProcessPrecision(t *testing.T) {
+	tests := []PrecisionTestCase{
+		{
+			Name:        "default precision",
+			Consumption: decimal.MustParse("31.444"),
+		},
+		{
+			Name:        "configured precision",
+			Precision:   &nem12.PrecisionRule{DecimalPlaces: 1, Rounding: decimal.RoundDown},
+			Consumption: decimal.MustParse("29.5"),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.Name, func(t *testing.T) {
+			// the files are processed in parallel, as every file has its own precision
+			t.Parallel()
+			file, err := os.Open("../test_files/sample.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			result, err := ingest.Process(context.Background(), file, ingest.Options{Precision: tt.Precision})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if result.MeterReadings[0].Consumption != tt.Consumption {
+				t.Errorf("Expected consumption %v, got %v instead", tt.Consumption, result.MeterReadings[0].Consumption)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+// ErrReconciliationMismatch is recorded for a NMI whose stored meter readings do not match the meter readings of its file.
+var ErrReconciliationMismatch = errors.New("stored meter readings do not match the file")
+
+// NmiReconciliation compares the meter readings of a NMI and suffix that were parsed from an NMI file
+// with the meter readings that are stored at the timestamps of the parsed meter readings.
+// A stored meter reading with a later UpdateDateTime than the parsed meter reading has superseded it,
+// so neither is compared, and it is counted in SupersededRows instead.
+type NmiReconciliation struct {
+	Nmi               string
+	NmiSuffix         string
+	ParsedRows        int
+	StoredRows        int
+	SupersededRows    int
+	ParsedConsumption decimal.Decimal
+	StoredConsumption decimal.Decimal
+}
+
+// Matches reports whether the stored meter readings have the same row count and consumption total as the parsed meter readings.
+func (r NmiReconciliation) Matches() bool {
+	return r.ParsedRows == r.StoredRows && r.ParsedConsumption == r.StoredConsumption
+}
+
+func (r NmiReconciliation) String() string {
+	s := fmt.Sprintf("%s %s: parsed %d readings with %s consumption, stored %d readings with %s consumption",
+		r.Nmi, r.NmiSuffix, r.ParsedRows, r.ParsedConsumption, r.StoredRows, r.StoredConsumption)
+	if r.SupersededRows > 0 {
+		s += fmt.Sprintf(", %d readings superseded", r.SupersededRows)
+	}
+	return s
+}
+
+// Reconciliation contains a NmiReconciliation for every NMI and suffix of an NMI file, ordered by NMI and suffix.
+type Reconciliation struct {
+	Nmis []NmiReconciliation
+}
+
+// Mismatches returns the NMIs whose stored meter readings do not match the file.
+func (r Reconciliation) Mismatches() []NmiReconciliation {
+	mismatches := []NmiReconciliation{}
+	for _, nmi := range r.Nmis {
+		if !nmi.Matches() {
+			mismatches = append(mismatches, nmi)
+		}
+	}
+	return mismatches
+}
+
+// Superseded returns the NMIs with stored meter readings that have superseded the meter readings of the file.
+func (r Reconciliation) Superseded() []NmiReconciliation {
+	superseded := []NmiReconciliation{}
+	for _, nmi := range r.Nmis {
+		if nmi.SupersededRows > 0 {
+			superseded = append(superseded, nmi)
+		}
+	}
+	return superseded
+}
+
+// Reconcile compares the row count and consumption total of the meter readings that were parsed from an NMI file
+// with the meter readings that repository now stores, for every NMI and suffix.
+// Only the stored meter readings with an UpdateDateTime at or before that of the parsed meter reading are compared,
+// as a later one is a revision that has superseded the file, which is reported in SupersededRows rather than as a mismatch.
+func Reconcile(ctx context.Context, repository repo.MeterReadingRepository, readings []*model.MeterReadings) (Reconciliation, error) {
+	totals := readingTotals{}
+	err := totals.add(readings)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	return totals.reconcile(ctx, repository)
+}
+
+// ReconcileFile processes the NMI file of fileName again, and reconciles its meter readings against meterReadings with Reconcile.
+// When fileProcessing is set, the mismatches replace those of an earlier reconciliation as failures of the latest completed
+// or partial FileProcessing record of the file, which is partial while it has any failure, and completed otherwise.
+func ReconcileFile(ctx context.Context, fileName string, meterReadings repo.MeterReadingRepository, fileProcessing repo.FileProcessingRepository) (Reconciliation, error) {
+	sink := NewMemorySink()
+	_, err := ProcessFile(ctx, fileName, Options{}, sink)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	reconciliation, err := Reconcile(ctx, meterReadings, sink.Result.MeterReadings)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	if fileProcessing == nil {
+		return reconciliation, nil
+	}
+
+	records, err := fileProcessing.FileProcessingByFileName(ctx, fileName)
+	if err != nil {
+		return reconciliation, fmt.Errorf("%s: %w", "Failed to find file processing records", err)
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Status != repo.FileStatusCompleted && record.Status != repo.FileStatusPartial {
+			continue
+		}
+		tracker := &fileTracker{repository: fileProcessing, record: record, failedNmis: mismatchedNmis(reconciliation.Mismatches())}
+		err = tracker.replaceMismatches(ctx)
+		if err != nil {
+			return reconciliation, fmt.Errorf("%s: %w", "Failed to update file processing record", err)
+		}
+		break
+	}
+	return reconciliation, nil
+}
+
+// mismatchedNmis returns a FailedNmi for every NMI with a mismatch, with an error for each of its suffixes that do not match,
+// so that it can be recorded as a failure of the file. mismatches are ordered by NMI, as they are in a Reconciliation.
+func mismatchedNmis(mismatches []NmiReconciliation) []FailedNmi {
+	failedNmis := []FailedNmi{}
+	for _, mismatch := range mismatches {
+		err := fmt.Errorf("%w: %s", ErrReconciliationMismatch, mismatch)
+		if last := len(failedNmis) - 1; last >= 0 && failedNmis[last].Nmi == mismatch.Nmi {
+			failedNmis[last].Errs = append(failedNmis[last].Errs, err)
+			continue
+		}
+		failedNmis = append(failedNmis, FailedNmi{Nmi: mismatch.Nmi, Errs: []error{err}})
+	}
+	return failedNmis
+}
+
+// isMismatch reports whether failure was recorded for a NMI whose stored meter readings did not match the file.
+func isMismatch(failure *model.FileProcessingFailures) bool {
+	return strings.HasPrefix(failure.Errors, ErrReconciliationMismatch.Error())
+}
+
+// streamKey is a NMI and suffix that meter readings are totalled by.
+type streamKey struct {
+	nmi       string
+	nmiSuffix string
+}
+
+// timestampTotals is the row count and consumption total of the meter readings of a NMI and suffix at a timestamp,
+// which has more than one row when a file repeats a day, along with their latest UpdateDateTime.
+type timestampTotals struct {
+	rows           int
+	consumption    decimal.Decimal
+	updateDateTime time.Time
+}
+
+// streamTotals is the totals of the meter readings of a NMI and suffix at each timestamp, and its first and last timestamp.
+type streamTotals struct {
+	// timestamps is keyed by the Unix time in microseconds, which is the precision of a Postgres timestamp
+	timestamps map[int64]*timestampTotals
+	first      time.Time
+	last       time.Time
+}
+
+// readingTotals totals meter readings by NMI, suffix and timestamp, so that a file can be reconciled without keeping its meter readings.
+type readingTotals map[streamKey]*streamTotals
+
+// add adds readings to the totals, or returns decimal.ErrOverflow when a consumption total cannot be held exactly.
+func (t readingTotals) add(readings []*model.MeterReadings) error {
+	for _, reading := range readings {
+		key := streamKey{nmi: reading.Nmi, nmiSuffix: reading.NmiSuffix}
+		totals, ok := t[key]
+		if !ok {
+			totals = &streamTotals{timestamps: map[int64]*timestampTotals{}, first: reading.Timestamp, last: reading.Timestamp}
+			t[key] = totals
+		}
+		timestamp, ok := totals.timestamps[reading.Timestamp.UnixMicro()]
+		if !ok {
+			timestamp = &timestampTotals{updateDateTime: reading.UpdateDateTime}
+			totals.timestamps[reading.Timestamp.UnixMicro()] = timestamp
+		}
+		consumption, err := timestamp.consumption.Add(reading.Consumption)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", reading.Nmi, reading.NmiSuffix, err)
+		}
+		timestamp.rows++
+		timestamp.consumption = consumption
+		if reading.UpdateDateTime.After(timestamp.updateDateTime) {
+			timestamp.updateDateTime = reading.UpdateDateTime
+		}
+		if reading.Timestamp.Before(totals.first) {
+			totals.first = reading.Timestamp
+		}
+		if reading.Timestamp.After(totals.last) {
+			totals.last = reading.Timestamp
+		}
+	}
+	return nil
+}
+
+// reconcile queries the stored meter readings of every NMI once, between the first and last reading of its suffixes,
+// and compares the stored meter readings at the timestamps of each suffix with its totals.
+// A stored meter reading on a day that the file skipped, such as one of another file, is not compared,
+// and neither is a stored meter reading that has superseded the file, nor the meter readings of the file at its timestamp.
+func (t readingTotals) reconcile(ctx context.Context, repository repo.MeterReadingRepository) (Reconciliation, error) {
+	keys := make([]streamKey, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].nmi != keys[j].nmi {
+			return keys[i].nmi < keys[j].nmi
+		}
+		return keys[i].nmiSuffix < keys[j].nmiSuffix
+	})
+
+	reconciliation := Reconciliation{Nmis: make([]NmiReconciliation, 0, len(keys))}
+	for start := 0; start < len(keys); {
+		end := start
+		from, to := t[keys[start]].first, t[keys[start]].last
+		for ; end < len(keys) && keys[end].nmi == keys[start].nmi; end++ {
+			totals := t[keys[end]]
+			if totals.first.Before(from) {
+				from = totals.first
+			}
+			if totals.last.After(to) {
+				to = totals.last
+			}
+		}
+		// to is excluded, so the range ends just after the last reading, at the microsecond precision of a Postgres timestamp
+		stored, err := repository.MeterReadingsByNmi(ctx, keys[start].nmi, from, to.Add(time.Microsecond))
+		if err != nil {
+			return Reconciliation{}, fmt.Errorf("%s: %w", "Failed to query meter readings", err)
+		}
+
+		for _, key := range keys[start:end] {
+			totals := t[key]
+			nmi := NmiReconciliation{Nmi: key.nmi, NmiSuffix: key.nmiSuffix}
+			superseded := map[int64]bool{}
+			for _, reading := range stored {
+				if reading.NmiSuffix != key.nmiSuffix {
+					continue
+				}
+				parsed, ok := totals.timestamps[reading.Timestamp.UnixMicro()]
+				if !ok {
+					continue
+				}
+				if reading.UpdateDateTime.After(parsed.updateDateTime) {
+					nmi.SupersededRows++
+					superseded[reading.Timestamp.UnixMicro()] = true
+					continue
+				}
+				nmi.StoredRows++
+				nmi.StoredConsumption, err = nmi.StoredConsumption.Add(reading.Consumption)
+				if err != nil {
+					return Reconciliation{}, fmt.Errorf("%s %s: %w", key.nmi, key.nmiSuffix, err)
+				}
+			}
+			for timestamp, parsed := range totals.timestamps {
+				if superseded[timestamp] {
+					continue
+				}
+				nmi.ParsedRows += parsed.rows
+				nmi.ParsedConsumption, err = nmi.ParsedConsumption.Add(parsed.consumption)
+				if err != nil {
+					return Reconciliation{}, fmt.Errorf("%s %s: %w", key.nmi, key.nmiSuffix, err)
+				}
+			}
+			reconciliation.Nmis = append(reconciliation.Nmis, nmi)
+		}
+		start = end
+	}
+	return reconciliation, nil
+}
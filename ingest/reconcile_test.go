@@ -0,0 +1,287 @@
+package ingest_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	"github.com/ts33/energy-reading/ingest"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+type ReconcileTestCase struct {
+	Name string
+	// Store changes the meter readings of sample.csv before they are stored
+	Store func(readings []*model.MeterReadings) []*model.MeterReadings
+	// Parse changes the meter readings of sample.csv before they are reconciled, when it is set
+	Parse    func(readings []*model.MeterReadings) []*model.MeterReadings
+	Expected []ingest.NmiReconciliation
+}
+
+func TestReconcile(t *testing.T) {
+	matched := []ingest.NmiReconciliation{
+		{Nmi: "NEM1201009", NmiSuffix: "E1", ParsedRows: 4, StoredRows: 4, ParsedConsumption: decimal.MustParse("127.679"), StoredConsumption: decimal.MustParse("127.679")},
+		{Nmi: "NEM1201010", NmiSuffix: "E2", ParsedRows: 4, StoredRows: 4, ParsedConsumption: decimal.MustParse("130.559"), StoredConsumption: decimal.MustParse("130.559")},
+	}
+	tests := []ReconcileTestCase{
+		{
+			Name: "stored file",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				return readings
+			},
+			Expected: matched,
+		},
+		{
+			Name: "stored file with readings of other days and suffixes",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				before, otherSuffix := *readings[0], *readings[0]
+				before.Timestamp = before.Timestamp.AddDate(0, 0, -1)
+				otherSuffix.NmiSuffix = "B1"
+				return append(readings, &before, &otherSuffix)
+			},
+			Expected: matched,
+		},
+		{
+			Name: "file that skips a day that another file has stored",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				return readings
+			},
+			Parse: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				return append([]*model.MeterReadings{readings[0]}, readings[2:]...)
+			},
+			Expected: []ingest.NmiReconciliation{
+				{Nmi: "NEM1201009", NmiSuffix: "E1", ParsedRows: 3, StoredRows: 3, ParsedConsumption: decimal.MustParse("95.439"), StoredConsumption: decimal.MustParse("95.439")},
+				matched[1],
+			},
+		},
+		{
+			Name: "missing reading",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				return readings[1:]
+			},
+			Expected: []ingest.NmiReconciliation{
+				{Nmi: "NEM1201009", NmiSuffix: "E1", ParsedRows: 4, StoredRows: 3, ParsedConsumption: decimal.MustParse("127.679"), StoredConsumption: decimal.MustParse("96.235")},
+				matched[1],
+			},
+		},
+		{
+			Name: "revised reading",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				revised := *readings[7]
+				revised.Consumption = decimal.MustParse("30.354")
+				revised.UpdateDateTime = revised.UpdateDateTime.Add(time.Hour)
+				return append(readings[:7], &revised)
+			},
+			Expected: []ingest.NmiReconciliation{
+				matched[0],
+				{Nmi: "NEM1201010", NmiSuffix: "E2", ParsedRows: 3, StoredRows: 3, SupersededRows: 1, ParsedConsumption: decimal.MustParse("99.205"), StoredConsumption: decimal.MustParse("99.205")},
+			},
+		},
+		{
+			Name: "reading of an earlier revision",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				earlier := *readings[7]
+				earlier.Consumption = decimal.MustParse("30.354")
+				earlier.UpdateDateTime = earlier.UpdateDateTime.Add(-time.Hour)
+				return append(readings[:7], &earlier)
+			},
+			Expected: []ingest.NmiReconciliation{
+				matched[0],
+				{Nmi: "NEM1201010", NmiSuffix: "E2", ParsedRows: 4, StoredRows: 4, ParsedConsumption: decimal.MustParse("130.559"), StoredConsumption: decimal.MustParse("129.559")},
+			},
+		},
+		{
+			Name: "nothing stored",
+			Store: func(readings []*model.MeterReadings) []*model.MeterReadings {
+				return nil
+			},
+			Expected: []ingest.NmiReconciliation{
+				{Nmi: "NEM1201009", NmiSuffix: "E1", ParsedRows: 4, ParsedConsumption: decimal.MustParse("127.679")},
+				{Nmi: "NEM1201010", NmiSuffix: "E2", ParsedRows: 4, ParsedConsumption: decimal.MustParse("130.559")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			stored, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			meterReadings := repo.NewMemoryMeterReadingRepository()
+			_, err = meterReadings.UpsertMeterReadings(ctx, tt.Store(stored.MeterReadings))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.Parse != nil {
+				parsed.MeterReadings = tt.Parse(parsed.MeterReadings)
+			}
+			reconciliation, err := ingest.Reconcile(ctx, meterReadings, parsed.MeterReadings)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if reflect.DeepEqual(tt.Expected, reconciliation.Nmis) != true {
+				t.Errorf("Expected %v, got %v instead", tt.Expected, reconciliation.Nmis)
+			}
+		})
+	}
+}
+
+type StreamReconcileTestCase struct {
+	Name    string
+	Revised bool
+	// Unstored reconciles the file against a repository that the sink does not store its meter readings in
+	Unstored   bool
+	Status     string
+	FailedNmis []string
+}
+
+func TestStreamReconcilesMeterReadings(t *testing.T) {
+	tests := []StreamReconcileTestCase{
+		{Name: "matching file", Status: repo.FileStatusCompleted},
+		{Name: "file with a reading that was revised before it was loaded", Revised: true, Status: repo.FileStatusCompleted},
+		{Name: "file whose readings were not stored", Unstored: true, Status: repo.FileStatusPartial, FailedNmis: []string{"NEM1201009", "NEM1201010"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			fileProcessing := repo.NewMemoryFileProcessingRepository()
+			meterReadings := repo.NewMemoryMeterReadingRepository()
+			if tt.Revised {
+				// a later revision of a reading of the file is kept when the file is loaded
+				_, err := meterReadings.UpsertMeterReadings(ctx, []*model.MeterReadings{{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Timestamp:      time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC),
+					Consumption:    decimal.MustParse("30.444"),
+					UpdateDateTime: time.Date(2005, 3, 11, 12, 10, 4, 0, time.UTC),
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := ingest.Options{FileProcessing: fileProcessing, Reconcile: meterReadings}
+			if tt.Unstored {
+				opts.Reconcile = repo.NewMemoryMeterReadingRepository()
+			}
+			_, err := ingest.ProcessFile(ctx, "../test_files/sample.csv", opts, ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), meterReadings, 0))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+
+			records, err := fileProcessing.FileProcessingByFileName(ctx, "../test_files/sample.csv")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if records[0].Status != tt.Status {
+				t.Errorf("Expected status %v, got %v instead", tt.Status, records[0].Status)
+			}
+			if int(records[0].FailedCount) != len(tt.FailedNmis) {
+				t.Errorf("Expected %v failed NMIs, got %v instead", len(tt.FailedNmis), records[0].FailedCount)
+			}
+			failures, err := fileProcessing.FileProcessingFailures(ctx, records[0].ID)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			assertFailedNmis(t, tt.FailedNmis, failures)
+		})
+	}
+}
+
+func TestReconcileFile(t *testing.T) {
+	ctx := context.Background()
+	fileProcessing := repo.NewMemoryFileProcessingRepository()
+	meterReadings := repo.NewMemoryMeterReadingRepository()
+	_, err := ingest.ProcessFile(ctx, "../test_files/sample.csv", ingest.Options{FileProcessing: fileProcessing}, ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), meterReadings, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file matches, so its record is left completed
+	reconciliation, err := ingest.ReconcileFile(ctx, "../test_files/sample.csv", meterReadings, fileProcessing)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(reconciliation.Nmis) != 2 || len(reconciliation.Mismatches()) != 0 {
+		t.Errorf("Expected 2 NMIs that match, got %v instead", reconciliation.Nmis)
+	}
+
+	// a reading that is missing from the stored meter readings is a mismatch, which marks the file as partial,
+	// and reconciling the file again replaces the mismatches of the earlier reconciliation, rather than recording them twice
+	missing := repo.NewMemoryMeterReadingRepository()
+	_, err = missing.UpsertMeterReadings(ctx, append(loaded.MeterReadings[:4:4], loaded.MeterReadings[5:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		reconciliation, err = ingest.ReconcileFile(ctx, "../test_files/sample.csv", missing, fileProcessing)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+	}
+	expected := []ingest.NmiReconciliation{
+		{Nmi: "NEM1201010", NmiSuffix: "E2", ParsedRows: 4, StoredRows: 3, ParsedConsumption: decimal.MustParse("130.559"), StoredConsumption: decimal.MustParse("97.369")},
+	}
+	if reflect.DeepEqual(expected, reconciliation.Mismatches()) != true {
+		t.Errorf("Expected %v, got %v instead", expected, reconciliation.Mismatches())
+	}
+
+	records, err := fileProcessing.FileProcessingByFileName(ctx, "../test_files/sample.csv")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(records) != 1 || records[0].Status != repo.FileStatusPartial || records[0].FailedCount != 1 {
+		t.Fatalf("Expected the loaded file to be partial with 1 failed NMI, got %+v instead", records)
+	}
+	failures, err := fileProcessing.FileProcessingFailures(ctx, records[0].ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	assertFailedNmis(t, []string{"NEM1201010"}, failures)
+	if expected := "stored meter readings do not match the file: NEM1201010 E2: parsed 4 readings with 130.559 consumption, stored 3 readings with 97.369 consumption"; failures[0].Errors != expected {
+		t.Errorf("Expected %v, got %v instead", expected, failures[0].Errors)
+	}
+
+	// a reading that is revised after the file was loaded has superseded it, so once the rest of the file matches,
+	// its mismatches are removed and it is completed
+	loaded.MeterReadings[4].UpdateDateTime = loaded.MeterReadings[4].UpdateDateTime.Add(time.Hour)
+	loaded.MeterReadings[4].Consumption = decimal.MustParse("0")
+	_, err = meterReadings.UpsertMeterReadings(ctx, loaded.MeterReadings[4:5])
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconciliation, err = ingest.ReconcileFile(ctx, "../test_files/sample.csv", meterReadings, fileProcessing)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(reconciliation.Mismatches()) != 0 || len(reconciliation.Superseded()) != 1 || reconciliation.Superseded()[0].Nmi != "NEM1201010" {
+		t.Errorf("Expected NEM1201010 to be superseded without a mismatch, got %v instead", reconciliation.Nmis)
+	}
+	records, err = fileProcessing.FileProcessingByFileName(ctx, "../test_files/sample.csv")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if records[0].Status != repo.FileStatusCompleted || records[0].FailedCount != 0 {
+		t.Errorf("Expected the loaded file to be completed with no failed NMIs, got %+v instead", records[0])
+	}
+	failures, err = fileProcessing.FileProcessingFailures(ctx, records[0].ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	assertFailedNmis(t, nil, failures)
+}
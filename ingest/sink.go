@@ -0,0 +1,232 @@
+package ingest
+
+import (
+	"context"
+	sql "database/sql"
+
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/nem12"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+// DefaultSinkBatchSize is the number of rows that a DatabaseSink buffers before they are inserted.
+const DefaultSinkBatchSize = 1000
+
+// Sink receives the output of an NMI file while it is being processed by Stream.
+// Its methods are never called concurrently, and a slow Sink holds back the workers, and in turn the reading of the file,
+// so that only a bounded number of NMI blocks are held in memory at once.
+type Sink interface {
+	// WriteHeader is called once with the 100 record, before any NMI block.
+	WriteHeader(ctx context.Context, header *model.FileHeaders) error
+	// WriteResults is called with the models of every NMI block that was processed.
+	WriteResults(ctx context.Context, results nem12.NmiResultsParams) error
+	// WriteFailedNmi is called for every NMI block that failed processing.
+	WriteFailedNmi(ctx context.Context, failedNmi FailedNmi) error
+	// Flush is called once every NMI block of the file has been written. It is not called when Stream fails.
+	Flush(ctx context.Context) error
+}
+
+// MemorySink is a Sink that appends everything it receives into a NmiFileResult.
+type MemorySink struct {
+	Result NmiFileResult
+}
+
+// NewMemorySink creates a MemorySink with an empty NmiFileResult.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{Result: newNmiFileResult()}
+}
+
+func (s *MemorySink) WriteHeader(ctx context.Context, header *model.FileHeaders) error {
+	s.Result.Header = header
+	return nil
+}
+
+func (s *MemorySink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	s.Result.DataStreams = append(s.Result.DataStreams, results.DataStreams...)
+	s.Result.MeterReadings = append(s.Result.MeterReadings, results.MeterReadings...)
+	s.Result.IntervalReadings = append(s.Result.IntervalReadings, results.IntervalReadings...)
+	s.Result.B2bDetails = append(s.Result.B2bDetails, results.B2bDetails...)
+	s.Result.AccumulationReadings = append(s.Result.AccumulationReadings, results.AccumulationReadings...)
+	return nil
+}
+
+func (s *MemorySink) WriteFailedNmi(ctx context.Context, failedNmi FailedNmi) error {
+	s.Result.FailedNmis = append(s.Result.FailedNmis, failedNmi)
+	return nil
+}
+
+func (s *MemorySink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// DatabaseSink is a Sink that stores NMI blocks in repositories once BatchSize rows have been buffered.
+// The file header is inserted into FileHeaders, meter readings are upserted into MeterReadings with the ID of the file header,
+// and the other models of every NMI block are stored in NmiBlocks.
+// The NMIs that failed processing are kept in FailedNmis, so that they can be handled for reruns,
+// and the readings and B2B details that were inserted, updated and skipped are counted for each table.
+type DatabaseSink struct {
+	BatchSize                  int
+	FileHeaders                repo.FileHeaderRepository
+	MeterReadings              repo.MeterReadingRepository
+	NmiBlocks                  repo.NmiBlockRepository
+	FailedNmis                 []FailedNmi
+	MeterReadingsResult        repo.UpsertResult
+	IntervalReadingsResult     repo.UpsertResult
+	AccumulationReadingsResult repo.UpsertResult
+	B2bDetailsResult           repo.UpsertResult
+	FileID                     uuid.UUID
+	buffered                   nem12.NmiResultsParams
+	rows                       int
+}
+
+// NewDatabaseSink creates a DatabaseSink that writes to the tables of db in batches of batchSize rows, which are inserted with opts.
+// batchSize defaults to DefaultSinkBatchSize.
+func NewDatabaseSink(db *sql.DB, batchSize int, opts repo.InsertOptions) *DatabaseSink {
+	return NewRepositorySink(repo.NewPostgresFileHeaderRepository(db), repo.NewPostgresMeterReadingRepository(db, opts),
+		repo.NewPostgresNmiBlockRepository(db, opts), batchSize)
+}
+
+// NewRepositorySink creates a DatabaseSink that writes to fileHeaders, meterReadings and nmiBlocks in batches of batchSize rows,
+// such as to memory repositories in tests. batchSize defaults to DefaultSinkBatchSize.
+func NewRepositorySink(fileHeaders repo.FileHeaderRepository, meterReadings repo.MeterReadingRepository, nmiBlocks repo.NmiBlockRepository, batchSize int) *DatabaseSink {
+	if batchSize < 1 {
+		batchSize = DefaultSinkBatchSize
+	}
+	return &DatabaseSink{
+		BatchSize:     batchSize,
+		FileHeaders:   fileHeaders,
+		MeterReadings: meterReadings,
+		NmiBlocks:     nmiBlocks,
+		FailedNmis:    []FailedNmi{},
+	}
+}
+
+func (s *DatabaseSink) WriteHeader(ctx context.Context, header *model.FileHeaders) error {
+	err := s.FileHeaders.InsertFileHeader(ctx, header)
+	s.FileID = header.ID
+	return err
+}
+
+func (s *DatabaseSink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	setFileID(results.MeterReadings, s.FileID)
+	s.buffered.DataStreams = append(s.buffered.DataStreams, results.DataStreams...)
+	s.buffered.MeterReadings = append(s.buffered.MeterReadings, results.MeterReadings...)
+	s.buffered.IntervalReadings = append(s.buffered.IntervalReadings, results.IntervalReadings...)
+	s.buffered.B2bDetails = append(s.buffered.B2bDetails, results.B2bDetails...)
+	s.buffered.AccumulationReadings = append(s.buffered.AccumulationReadings, results.AccumulationReadings...)
+	s.rows += len(results.DataStreams) + len(results.MeterReadings) + len(results.IntervalReadings) +
+		len(results.B2bDetails) + len(results.AccumulationReadings)
+	if s.rows < s.BatchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+func (s *DatabaseSink) WriteFailedNmi(ctx context.Context, failedNmi FailedNmi) error {
+	s.FailedNmis = append(s.FailedNmis, failedNmi)
+	return nil
+}
+
+// InsertedRows returns the number of meter readings and accumulation readings that were inserted or updated.
+// Interval readings are not counted, as they are split from the same records as the meter readings.
+func (s *DatabaseSink) InsertedRows() int64 {
+	return s.MeterReadingsResult.Inserted + s.MeterReadingsResult.Updated +
+		s.AccumulationReadingsResult.Inserted + s.AccumulationReadingsResult.Updated
+}
+
+// Flush inserts the rows that are buffered, and upserts the readings. Data streams are upserted first, as the readings refer to them.
+func (s *DatabaseSink) Flush(ctx context.Context) error {
+	err := s.NmiBlocks.UpsertDataStreams(ctx, s.buffered.DataStreams)
+	if err != nil {
+		return err
+	}
+	result, err := s.MeterReadings.UpsertMeterReadings(ctx, s.buffered.MeterReadings)
+	s.MeterReadingsResult = s.MeterReadingsResult.Add(result)
+	if err != nil {
+		return err
+	}
+	result, err = s.NmiBlocks.UpsertIntervalReadings(ctx, s.buffered.IntervalReadings)
+	s.IntervalReadingsResult = s.IntervalReadingsResult.Add(result)
+	if err != nil {
+		return err
+	}
+	result, err = s.NmiBlocks.InsertB2bDetails(ctx, s.buffered.B2bDetails)
+	s.B2bDetailsResult = s.B2bDetailsResult.Add(result)
+	if err != nil {
+		return err
+	}
+	result, err = s.NmiBlocks.UpsertAccumulationReadings(ctx, s.buffered.AccumulationReadings)
+	s.AccumulationReadingsResult = s.AccumulationReadingsResult.Add(result)
+	if err != nil {
+		return err
+	}
+	s.buffered = nem12.NmiResultsParams{}
+	s.rows = 0
+	return nil
+}
+
+// MeterReadingSink is a Sink that upserts the meter readings of NMI blocks into a MeterReadingRepository
+// once BatchSize meter readings have been buffered. The other models of an NMI block are not kept.
+// The file header is inserted into FileHeaders, and the meter readings are given its ID, so that they can be deleted by file.
+type MeterReadingSink struct {
+	FileHeaders repo.FileHeaderRepository
+	Repository  repo.MeterReadingRepository
+	BatchSize   int
+	FileID      uuid.UUID
+	FailedNmis  []FailedNmi
+	Result      repo.UpsertResult
+	buffered    []*model.MeterReadings
+}
+
+// NewMeterReadingSink creates a MeterReadingSink that inserts the file header to fileHeaders,
+// and writes to repository in batches of batchSize meter readings. batchSize defaults to DefaultSinkBatchSize.
+func NewMeterReadingSink(fileHeaders repo.FileHeaderRepository, repository repo.MeterReadingRepository, batchSize int) *MeterReadingSink {
+	if batchSize < 1 {
+		batchSize = DefaultSinkBatchSize
+	}
+	return &MeterReadingSink{FileHeaders: fileHeaders, Repository: repository, BatchSize: batchSize, FailedNmis: []FailedNmi{}}
+}
+
+func (s *MeterReadingSink) WriteHeader(ctx context.Context, header *model.FileHeaders) error {
+	err := s.FileHeaders.InsertFileHeader(ctx, header)
+	s.FileID = header.ID
+	return err
+}
+
+func (s *MeterReadingSink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	setFileID(results.MeterReadings, s.FileID)
+	s.buffered = append(s.buffered, results.MeterReadings...)
+	if len(s.buffered) < s.BatchSize {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+func (s *MeterReadingSink) WriteFailedNmi(ctx context.Context, failedNmi FailedNmi) error {
+	s.FailedNmis = append(s.FailedNmis, failedNmi)
+	return nil
+}
+
+// InsertedRows returns the number of meter readings that were inserted or updated.
+func (s *MeterReadingSink) InsertedRows() int64 {
+	return s.Result.Inserted + s.Result.Updated
+}
+
+// Flush upserts the meter readings that are buffered.
+func (s *MeterReadingSink) Flush(ctx context.Context) error {
+	result, err := s.Repository.UpsertMeterReadings(ctx, s.buffered)
+	s.Result = s.Result.Add(result)
+	if err != nil {
+		return err
+	}
+	s.buffered = nil
+	return nil
+}
+
+// setFileID sets the ID of the file header that a list of MeterReadings were read from.
+func setFileID(readings []*model.MeterReadings, fileID uuid.UUID) {
+	for _, reading := range readings {
+		reading.FileID = &fileID
+	}
+}
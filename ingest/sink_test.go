@@ -0,0 +1,303 @@
+package ingest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/ingest"
+	"github.com/ts33/energy-reading/nem12"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+func TestStreamMemorySink(t *testing.T) {
+	expected, err := ingest.ProcessNmiFile("../test_files/sample_err_multiple.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	file, err := os.Open("../test_files/sample_err_multiple.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sink := ingest.NewMemorySink()
+	_, err = ingest.Stream(context.Background(), file, ingest.Options{FileName: "../test_files/sample_err_multiple.csv"}, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if reflect.DeepEqual(expected, sink.Result) != true {
+		t.Errorf("Expected %+v, got %+v instead", expected, sink.Result)
+	}
+}
+
+func TestStreamCallsSinkInOrder(t *testing.T) {
+	file, err := os.Open("../test_files/sample_100.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sink := &recordingSink{}
+	_, err = ingest.Stream(context.Background(), file, ingest.Options{NumWorkers: 4}, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(sink.calls) != 102 || sink.calls[0] != "header" || sink.calls[101] != "flush" {
+		t.Errorf("Expected a header, 100 results and a flush, got %v instead", sink.calls)
+	}
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	content, err := os.ReadFile("../test_files/sample_100.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := &countingReader{Reader: bytes.NewReader(content)}
+	sink := &blockingSink{release: make(chan struct{})}
+
+	done := make(chan error)
+	go func() {
+		_, err := ingest.Stream(context.Background(), reader, ingest.Options{NumWorkers: 2}, sink)
+		done <- err
+	}()
+
+	// while the sink is blocked, the file is only read until the channels are full
+	time.Sleep(200 * time.Millisecond)
+	if read := reader.read.Load(); read >= int64(len(content)/2) {
+		t.Errorf("Expected reading to be held back by the sink, got %v of %v bytes read instead", read, len(content))
+	}
+	close(sink.release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if sink.results != 100 {
+		t.Errorf("Expected 100 results, got %v instead", sink.results)
+	}
+}
+
+func TestStreamStopsOnSinkError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	file, err := os.Open("../test_files/sample_100.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sinkErr := errors.New("datastore is unavailable")
+	sink := &recordingSink{failAfter: 3, err: sinkErr}
+	_, err = ingest.Stream(context.Background(), file, ingest.Options{NumWorkers: 4}, sink)
+	if !errors.Is(err, sinkErr) {
+		t.Errorf("Expected err %v, got %v instead", sinkErr, err)
+	}
+	if sink.calls[len(sink.calls)-1] == "flush" {
+		t.Errorf("Expected the sink not to be flushed, got %v instead", sink.calls)
+	}
+	assertGoroutinesStopped(t, before)
+}
+
+// recordingSink records the calls that it receives, and fails once failAfter results have been written.
+type recordingSink struct {
+	calls     []string
+	failAfter int
+	err       error
+}
+
+func (s *recordingSink) WriteHeader(ctx context.Context, header *model.FileHeaders) error {
+	s.calls = append(s.calls, "header")
+	return nil
+}
+
+func (s *recordingSink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	s.calls = append(s.calls, "results")
+	if s.err != nil && len(s.calls) > s.failAfter {
+		return s.err
+	}
+	return nil
+}
+
+func (s *recordingSink) WriteFailedNmi(ctx context.Context, failedNmi ingest.FailedNmi) error {
+	s.calls = append(s.calls, "failed")
+	return nil
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error {
+	s.calls = append(s.calls, "flush")
+	return nil
+}
+
+// blockingSink blocks every write until release is closed.
+type blockingSink struct {
+	release chan struct{}
+	results int
+}
+
+func (s *blockingSink) WriteHeader(ctx context.Context, header *model.FileHeaders) error {
+	return nil
+}
+
+func (s *blockingSink) WriteResults(ctx context.Context, results nem12.NmiResultsParams) error {
+	<-s.release
+	s.results++
+	return nil
+}
+
+func (s *blockingSink) WriteFailedNmi(ctx context.Context, failedNmi ingest.FailedNmi) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// countingReader reads in small chunks, and counts the bytes that have been read.
+type countingReader struct {
+	io.Reader
+	read atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if len(p) > 512 {
+		p = p[:512]
+	}
+	n, err := r.Reader.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+func TestStreamMeterReadingSink(t *testing.T) {
+	expected, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	file, err := os.Open("../test_files/sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	repository := repo.NewMemoryMeterReadingRepository()
+	sink := ingest.NewMeterReadingSink(repo.NewMemoryFileHeaderRepository(), repository, 3)
+	_, err = ingest.Stream(ctx, file, ingest.Options{NumWorkers: 2}, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if result := (repo.UpsertResult{Inserted: int64(len(expected.MeterReadings))}); sink.Result != result {
+		t.Errorf("Expected %+v, got %+v instead", result, sink.Result)
+	}
+
+	from, to := time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2005, 4, 1, 0, 0, 0, 0, time.UTC)
+	readings, err := repository.MeterReadingsByNmi(ctx, "NEM1201009", from, to)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	stored := 0
+	for _, reading := range expected.MeterReadings {
+		if reading.Nmi == "NEM1201009" {
+			stored++
+		}
+	}
+	if len(readings) != stored {
+		t.Fatalf("Expected %v readings, got %v instead", stored, len(readings))
+	}
+	for _, reading := range readings {
+		if reading.FileID == nil || *reading.FileID != sink.FileID {
+			t.Errorf("Expected the reading to refer to file %v, got %+v instead", sink.FileID, reading)
+		}
+	}
+	header, err := sink.FileHeaders.FileHeaderByID(ctx, sink.FileID)
+	if err != nil {
+		t.Fatalf("Expected the file header that the readings refer to, got %v instead", err)
+	}
+	if header.FromParticipant != "UNITEDDP" {
+		t.Errorf("Expected the file header of sample.csv, got %+v instead", header)
+	}
+
+	deleted, err := repository.DeleteMeterReadingsByFile(ctx, sink.FileID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if deleted != int64(len(expected.MeterReadings)) {
+		t.Errorf("Expected %v readings to be deleted, got %v instead", len(expected.MeterReadings), deleted)
+	}
+}
+
+func TestProcessFileDatabaseSink(t *testing.T) {
+	expected, err := ingest.ProcessNmiFile("../test_files/sample.csv", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	ctx := context.Background()
+	fileHeaders := repo.NewMemoryFileHeaderRepository()
+	meterReadings := repo.NewMemoryMeterReadingRepository()
+	nmiBlocks := repo.NewMemoryNmiBlockRepository()
+	fileProcessing := repo.NewMemoryFileProcessingRepository()
+
+	// the pipeline of main, with memory repositories in place of the database
+	sink := ingest.NewRepositorySink(fileHeaders, meterReadings, nmiBlocks, 5)
+	opts := ingest.Options{NumWorkers: 2, FileProcessing: fileProcessing, Reconcile: meterReadings}
+	_, err = ingest.ProcessFile(ctx, "../test_files/sample.csv", opts, sink)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	header, err := fileHeaders.FileHeaderByID(ctx, sink.FileID)
+	if err != nil {
+		t.Fatalf("Expected the file header to be stored, got %v instead", err)
+	}
+	if header.FileName != "../test_files/sample.csv" || header.FromParticipant != expected.Header.FromParticipant {
+		t.Errorf("Expected %+v, got %+v instead", expected.Header, header)
+	}
+	if result := (repo.UpsertResult{Inserted: int64(len(expected.MeterReadings))}); sink.MeterReadingsResult != result {
+		t.Errorf("Expected %+v, got %+v instead", result, sink.MeterReadingsResult)
+	}
+	if result := (repo.UpsertResult{Inserted: int64(len(expected.IntervalReadings))}); sink.IntervalReadingsResult != result {
+		t.Errorf("Expected %+v, got %+v instead", result, sink.IntervalReadingsResult)
+	}
+
+	for _, nmi := range []string{"NEM1201009", "NEM1201010"} {
+		dataStreams, err := nmiBlocks.DataStreamsByNmi(ctx, nmi)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+		if len(dataStreams) != 1 || dataStreams[0].Nmi != nmi {
+			t.Errorf("Expected the data stream of %v, got %+v instead", nmi, dataStreams)
+		}
+		intervalReadings, err := nmiBlocks.IntervalReadingsByNmi(ctx, nmi)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+		if len(intervalReadings) != len(expected.IntervalReadings)/2 {
+			t.Errorf("Expected %v interval readings of %v, got %v instead", len(expected.IntervalReadings)/2, nmi, len(intervalReadings))
+		}
+		from, to := time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2005, 4, 1, 0, 0, 0, 0, time.UTC)
+		readings, err := meterReadings.MeterReadingsByNmi(ctx, nmi, from, to)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v instead", err)
+		}
+		for _, reading := range readings {
+			if reading.FileID == nil || *reading.FileID != header.ID {
+				t.Errorf("Expected the reading to refer to file %v, got %+v instead", header.ID, reading)
+			}
+		}
+	}
+
+	records, err := fileProcessing.FileProcessingByFileName(ctx, "../test_files/sample.csv")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(records) != 1 || records[0].Status != repo.FileStatusCompleted || records[0].InsertedCount != sink.InsertedRows() {
+		t.Errorf("Expected a completed record with %v inserted rows, got %+v instead", sink.InsertedRows(), records)
+	}
+}
@@ -1,31 +1,21 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
+	"flag"
 	"fmt"
-	"math"
 	"os"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	sql "database/sql"
 	_ "github.com/lib/pq"
-	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/ingest"
+	"github.com/ts33/energy-reading/monitor"
 	repo "github.com/ts33/energy-reading/repository"
 )
 
 const (
-	RecordIndicator_100      = "100"
-	RecordIndicator_200      = "200"
-	RecordIndicator_300      = "300"
-	RecordIndicator_500      = "500"
-	RecordIndicator_900      = "900"
-	RecordTimestampLayout    = "20060102"
-	MeterReadingDecimalPlace = 3
-
 	dbHost     = "localhost"
 	dbPort     = 5432
 	dbUser     = "test123"
@@ -33,21 +23,13 @@ const (
 	dbName     = "postgres"
 )
 
-// MeterReadingFactor is the number of decimal places a MeterReading should be restricted to.
-var MeterReadingFactor = math.Pow(10, float64(MeterReadingDecimalPlace))
-
-// NmiWorkerParams contains a slice of NMI 300 records that belong to a NMI 200 block.
-type NmiWorkerParams struct {
-	NmiBlockRecords []string
-	Nmi             string
-}
-
-// NmiResultsParams contains a slice of MeterReadings that are ready to be inserted into the datastore.
-type NmiResultsParams struct {
-	MeterReadings []*model.MeterReadings
-}
-
 func main() {
+	force := flag.Bool("force", false, "process the file again, even when a file with the same content has completed")
+	participants := flag.String("participants", "", "comma separated participants that are expected to deliver a file every day, for monitor")
+	webhook := flag.String("webhook", "", "URL that the alerts of monitor are posted to")
+	alertCommand := flag.String("alert-command", "", "command that is run with the alert on its standard input, for every alert of monitor")
+	flag.Parse()
+
 	// 1. setup db
 	var connectString = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPassword, dbName)
 	db, err := sql.Open("postgres", connectString)
@@ -56,182 +38,71 @@ func main() {
 		panic(err)
 	}
 	defer db.Close()
-
-	// 2. Process NMI File
-	readings, _, err := ProcessNmiFile("test_files/sample.csv", 1)
-	// NMI failures can be handled for reruns
-	if err != nil {
-		panic(err)
-	}
-
-	// 3. write to DB
-	err = repo.BulkInsertMeterReadings(db, readings)
-	// to be handled by caller
-	if err != nil {
-		panic(err)
-	}
-}
-
-// ProcessNmiFile reads an NMI file, processes it and saves the records into a datastore.
-func ProcessNmiFile(fileName string, numWorkers int) (allReadings []*model.MeterReadings, failedNmis []string, err error) {
-	allReadings = []*model.MeterReadings{}
-	failedNmis = []string{}
-
-	// 1. Open the file
-	file, err := os.Open(fileName)
-	if err != nil {
-		return allReadings, failedNmis, err
-	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-
-	// 2. Check that file starts with 100
-	valid := scanner.Scan()
-	if !valid {
-		return allReadings, failedNmis, errors.New("unable to read first line")
-	}
-	line := scanner.Text()
-	if line[:3] != RecordIndicator_100 {
-		return allReadings, failedNmis, errors.New("first record is not a 100 record")
-	}
-
-	// 3.1 Create channels for work distribution - round workers to nearest multiple of 2
-	// good reference: https://stackoverflow.com/a/50261948/471538
-	jobsChan := make(chan NmiWorkerParams, numWorkers)
-	resultsChan := make(chan NmiResultsParams, numWorkers)
-	failedChan := make(chan string, numWorkers)
-	var wgWorker, wgOutput sync.WaitGroup
-	var muResults, muFailed sync.Mutex
-
-	// 3.2 Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
-		wgWorker.Add(1)
-		go NmiBlockWorker(jobsChan, &wgWorker, resultsChan, failedChan)
-	}
-	wgOutput.Add(2)
-	// 3.3 Start goroutine that reads from results
-	go func() {
-		defer wgOutput.Done()
-		for result := range resultsChan {
-			muResults.Lock()
-			allReadings = append(allReadings, result.MeterReadings...)
-			muResults.Unlock()
+	insertOptions := repo.InsertOptions{TxMode: repo.TransactionPerChunk}
+	meterReadings := repo.NewPostgresMeterReadingRepository(db, insertOptions)
+	fileProcessing := repo.NewPostgresFileProcessingRepository(db, insertOptions)
+
+	// `reconcile <file>` compares a file that has been loaded with the meter readings that are now stored
+	if flag.Arg(0) == "reconcile" {
+		if flag.NArg() != 2 {
+			fmt.Println("Usage: reconcile <file>")
+			os.Exit(2)
 		}
-	}()
-	// 3.4 Start goroutine that reads from failedChan
-	go func() {
-		defer wgOutput.Done()
-		for failedNmi := range failedChan {
-			muFailed.Lock()
-			failedNmis = append(failedNmis, failedNmi)
-			muFailed.Unlock()
+		reconciliation, err := ingest.ReconcileFile(context.Background(), flag.Arg(1), meterReadings, fileProcessing)
+		// to be handled by caller
+		if err != nil {
+			panic(err)
 		}
-	}()
-
-	// 4. loop through file and process nmiBlocks
-	var nmiBlockRecords []string
-	var nem string
-
-	for scanner.Scan() {
-		line = scanner.Text()
-		indicator := line[:3]
-
-		switch indicator {
-		case RecordIndicator_200:
-			// process the previous batch if available
-			if len(nmiBlockRecords) > 0 {
-				jobsChan <- NmiWorkerParams{nmiBlockRecords, nem}
-				// reset blocks
-				nmiBlockRecords = []string{}
-			}
-			// capture the new NEM value
-			splitLine := strings.Split(line, ",")
-			nem = splitLine[1]
-		case RecordIndicator_300:
-			nmiBlockRecords = append(nmiBlockRecords, line)
-		case RecordIndicator_900:
-			// process the last batch
-			if len(nmiBlockRecords) > 0 {
-				jobsChan <- NmiWorkerParams{nmiBlockRecords, nem}
-			}
-		default:
-			continue
+		mismatches := reconciliation.Mismatches()
+		for _, mismatch := range mismatches {
+			fmt.Println("Mismatch:", mismatch)
 		}
-	}
-
-	// 5. Validate end of file indicator
-	if line[:3] != RecordIndicator_900 {
-		return allReadings, failedNmis, errors.New("last record is not a 900 record")
-	}
-
-	// 6.1 Explicitly close jobs channels as file reading is complete
-	// good reference: https://stackoverflow.com/a/59639259/471538
-	close(jobsChan)
-	// 6.2 Wait for all workers to finish
-	wgWorker.Wait()
-	// 6.3 close results and errors channel as all workers are done
-	close(resultsChan)
-	close(failedChan)
-	// 6.4 Wait for the two output go routines to finish
-	wgOutput.Wait()
-
-	return allReadings, failedNmis, nil
-}
-
-// NmiBlockWorker is a worker that receives nmiBlocks, processes them and sends the output to the results channel.
-func NmiBlockWorker(jobsChan <-chan NmiWorkerParams, wg *sync.WaitGroup, resultsChan chan<- NmiResultsParams, failedChan chan<- string) {
-	defer wg.Done()
-	for j := range jobsChan {
-		readings, err := ProcessNmiBlock(j.NmiBlockRecords, j.Nmi)
-		// push err to error chan if it exists, for reconciliation
-		if err != nil {
-			failedChan <- j.Nmi
-		} else {
-			resultsChan <- NmiResultsParams{readings}
+		superseded := reconciliation.Superseded()
+		for _, nmi := range superseded {
+			fmt.Println("Superseded:", nmi)
+		}
+		fmt.Printf("Reconciled %d NMIs of %s, %d did not match, %d had readings superseded by later revisions\n",
+			len(reconciliation.Nmis), flag.Arg(1), len(mismatches), len(superseded))
+		if len(mismatches) > 0 {
+			os.Exit(1)
 		}
+		return
 	}
-}
-
-// ProcessNmiBlock creates a MeterReadings model object for each nmiBlockRecord received.
-func ProcessNmiBlock(nmiBlockRecords []string, nmi string) (meterReadings []*model.MeterReadings, err error) {
-	meterReadings = []*model.MeterReadings{}
 
-	for _, nmiBlockRecord := range nmiBlockRecords {
-		splitLine := strings.Split(nmiBlockRecord, ",")
-		if len(splitLine) < 51 {
-			return meterReadings, errors.New("meter reading does not have enough values")
+	// `monitor` checks file_processing once for stalled files, files with failed NMIs and missing deliveries
+	if flag.Arg(0) == "monitor" {
+		notifiers := []monitor.Notifier{&monitor.LogNotifier{}}
+		if *webhook != "" {
+			notifiers = append(notifiers, monitor.NewWebhookNotifier(*webhook))
 		}
-		timestamp, err := time.Parse(RecordTimestampLayout, splitLine[1])
-		if err != nil {
-			return meterReadings, fmt.Errorf("%s: %w", "Failed to parse time value", err)
+		if *alertCommand != "" {
+			notifiers = append(notifiers, &monitor.CommandNotifier{Name: *alertCommand})
 		}
-		sum, err := sumConsumptionValues(splitLine[2:50])
-		if err != nil {
-			return meterReadings, fmt.Errorf("%s: %w", "Failed to parse consumption value to float", err)
+		config := monitor.Config{}
+		if *participants != "" {
+			config.ExpectedParticipants = strings.Split(*participants, ",")
 		}
-
-		meterReading := &model.MeterReadings{
-			Nmi:         nmi,
-			Timestamp:   timestamp,
-			Consumption: sum,
+		alerts, err := monitor.NewMonitor(fileProcessing, config, notifiers...).Check(context.Background(), time.Now().UTC())
+		// to be handled by caller
+		if err != nil {
+			panic(err)
 		}
-		meterReadings = append(meterReadings, meterReading)
+		fmt.Printf("Raised %d alerts\n", len(alerts))
+		return
 	}
-	return meterReadings, nil
-}
 
-// sumConsumptionValues takes in a list of stringified floats and sums them up.
-// It also forces the floats and the end value to be restricted to only a set amount of decimal places based on MeterReadingFactor.
-func sumConsumptionValues(numbers []string) (sum float64, err error) {
-	sum = 0.0
-	for _, num := range numbers {
-		val, err := strconv.ParseFloat(num, 64)
-		if err != nil {
-			return 0.0, err
-		}
-		val = math.Round(val*MeterReadingFactor) / MeterReadingFactor
-		sum += val
+	// 2. Process NMI File, and write it to the DB as NMI blocks complete, tracking its status in file_processing
+	// and reconciling it against meter_readings once it is loaded
+	sink := ingest.NewDatabaseSink(db, ingest.DefaultSinkBatchSize, insertOptions)
+	opts := ingest.Options{NumWorkers: 1, FileProcessing: fileProcessing, Force: *force, Reconcile: meterReadings}
+	result, err := ingest.ProcessFile(context.Background(), "test_files/sample.csv", opts, sink)
+	if result.Outcome == ingest.OutcomeSkipped {
+		fmt.Printf("Skipped test_files/sample.csv, as it has the same content as file processing record %s\n", result.DuplicateOf)
+		return
+	}
+	// to be handled by caller
+	if err != nil {
+		panic(err)
 	}
-	// this can be rounded up or down depending on requirements
-	return math.Round(sum*MeterReadingFactor) / MeterReadingFactor, nil
+	// NMI failures can be handled for reruns, with sink.FailedNmis or the file_processing_failures table
 }
@@ -0,0 +1,268 @@
+// Package monitor checks the FileProcessing records of NMI files for files that have stalled, files that failed
+// or have NMIs that failed, and daily deliveries that never arrived, and raises an Alert for each of them through a Notifier.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+const (
+	// DefaultProcessingTimeout is how long a file can be received or processing before it has stalled.
+	DefaultProcessingTimeout = time.Hour
+	// DefaultWindow is how far back files are checked for failed NMIs and deliveries, which is a day for daily deliveries.
+	DefaultWindow = 24 * time.Hour
+)
+
+// AlertKind is the condition that an Alert is raised for.
+type AlertKind string
+
+const (
+	// AlertStalledFile is raised for a file that is still received or processing after the processing timeout.
+	AlertStalledFile AlertKind = "stalled_file"
+	// AlertFailedNmis is raised for a partial file, which has NMIs that failed processing or did not reconcile.
+	AlertFailedNmis AlertKind = "failed_nmis"
+	// AlertFailedFile is raised for a file that failed processing, along with the NMIs that failed before it did.
+	AlertFailedFile AlertKind = "failed_file"
+	// AlertMissingDelivery is raised for an expected participant that has not delivered a file within the window.
+	AlertMissingDelivery AlertKind = "missing_delivery"
+)
+
+// Alert is raised by a Monitor for a file, or for a participant whose delivery is missing.
+type Alert struct {
+	Kind             AlertKind  `json:"kind"`
+	Message          string     `json:"message"`
+	FileProcessingID *uuid.UUID `json:"file_processing_id,omitempty"`
+	FileName         string     `json:"file_name,omitempty"`
+	Participant      string     `json:"participant,omitempty"`
+	Nmis             []string   `json:"nmis,omitempty"`
+	RaisedAt         time.Time  `json:"raised_at"`
+}
+
+// Config configures the checks of a Monitor.
+type Config struct {
+	// ProcessingTimeout is how long a file can be received or processing before it has stalled. It defaults to DefaultProcessingTimeout.
+	ProcessingTimeout time.Duration
+	// Window is how far back files are checked for failed NMIs and deliveries. It defaults to DefaultWindow.
+	Window time.Duration
+	// ExpectedParticipants are the participants that deliver a file every day, as the FromParticipant of its 100 record.
+	ExpectedParticipants []string
+}
+
+// Monitor checks the FileProcessing records of Repository, and raises alerts through every notifier.
+// An alert is only raised once through every notifier, and is raised again when its condition persists for longer than the window.
+// It is safe for concurrent use, while Notifiers is not changed.
+type Monitor struct {
+	Repository repo.FileProcessingRepository
+	Config     Config
+	Notifiers  []Notifier
+	mu         sync.Mutex
+	// alerted holds every alert that has been raised, by the key of its condition
+	alerted map[string]*raisedAlert
+}
+
+// raisedAlert is an alert that has been raised at raisedAt, along with the index of every notifier that it has been raised through.
+type raisedAlert struct {
+	raisedAt time.Time
+	notified map[int]bool
+}
+
+// NewMonitor creates a Monitor that checks repository with config, and raises alerts through notifiers.
+func NewMonitor(repository repo.FileProcessingRepository, config Config, notifiers ...Notifier) *Monitor {
+	if config.ProcessingTimeout <= 0 {
+		config.ProcessingTimeout = DefaultProcessingTimeout
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+	return &Monitor{Repository: repository, Config: config, Notifiers: notifiers, alerted: map[string]*raisedAlert{}}
+}
+
+// Run checks the FileProcessing records every interval, until ctx is cancelled and ctx.Err() is returned.
+// A check that fails is logged, and does not stop the monitor.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := m.Check(ctx, time.Now().UTC())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("file processing monitor: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check finds the files that have stalled as of now, the partial and failed files and the missing deliveries of the window
+// that ends at now, and raises an alert for each of them that has not been raised before. The alerts raised are returned.
+// An alert that a notifier fails to raise is returned along with the error, and is raised again by the next check,
+// through only the notifiers that have not raised it yet.
+func (m *Monitor) Check(ctx context.Context, now time.Time) ([]Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts, err := m.stalledFiles(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	received, err := m.Repository.FileProcessingReceivedBetween(ctx, now.Add(-m.Config.Window), now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "Failed to find file processing records", err)
+	}
+	failed, err := m.failedFiles(ctx, received, now)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, failed...)
+	alerts = append(alerts, m.missingDeliveries(received, now)...)
+
+	for key, alerted := range m.alerted {
+		if now.Sub(alerted.raisedAt) > m.Config.Window {
+			delete(m.alerted, key)
+		}
+	}
+	raised := []Alert{}
+	var errs []error
+	for _, alert := range alerts {
+		key := alertKey(alert)
+		alerted, ok := m.alerted[key]
+		if !ok {
+			alerted = &raisedAlert{raisedAt: now, notified: map[int]bool{}}
+			m.alerted[key] = alerted
+		} else if len(alerted.notified) == len(m.Notifiers) {
+			continue
+		}
+		err := m.notify(ctx, alert, alerted)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		raised = append(raised, alert)
+	}
+	return raised, errors.Join(errs...)
+}
+
+// stalledFiles returns an alert for every file that has been received or processing for longer than the processing timeout.
+func (m *Monitor) stalledFiles(ctx context.Context, now time.Time) ([]Alert, error) {
+	records, err := m.Repository.FileProcessingByStatus(ctx, repo.FileStatusReceived, repo.FileStatusProcessing)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "Failed to find file processing records", err)
+	}
+	alerts := []Alert{}
+	for _, record := range records {
+		since := record.ReceivedAt
+		if record.StartedAt != nil {
+			since = *record.StartedAt
+		}
+		if now.Sub(since) <= m.Config.ProcessingTimeout {
+			continue
+		}
+		alert := fileAlert(AlertStalledFile, record, now)
+		alert.Message = fmt.Sprintf("file %s has been %s since %s", record.FileName, record.Status, since.Format(time.RFC3339))
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// failedFiles returns an alert for every partial and failed file of records, along with the NMIs that failed.
+func (m *Monitor) failedFiles(ctx context.Context, records []*model.FileProcessing, now time.Time) ([]Alert, error) {
+	alerts := []Alert{}
+	for _, record := range records {
+		if record.Status != repo.FileStatusPartial && record.Status != repo.FileStatusFailed {
+			continue
+		}
+		failures, err := m.Repository.FileProcessingFailures(ctx, record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", "Failed to find file processing failures", err)
+		}
+		nmis := make([]string, 0, len(failures))
+		for _, failure := range failures {
+			nmis = append(nmis, failure.Nmi)
+		}
+		var alert Alert
+		switch {
+		case record.Status == repo.FileStatusPartial:
+			alert = fileAlert(AlertFailedNmis, record, now)
+			alert.Message = fmt.Sprintf("file %s has %d failed NMIs: %s", record.FileName, len(nmis), strings.Join(nmis, ", "))
+		case len(nmis) > 0:
+			alert = fileAlert(AlertFailedFile, record, now)
+			alert.Message = fmt.Sprintf("file %s failed, with %d failed NMIs: %s", record.FileName, len(nmis), strings.Join(nmis, ", "))
+		default:
+			alert = fileAlert(AlertFailedFile, record, now)
+			alert.Message = fmt.Sprintf("file %s failed", record.FileName)
+		}
+		alert.Nmis = nmis
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// missingDeliveries returns an alert for every expected participant that did not deliver any of records.
+func (m *Monitor) missingDeliveries(records []*model.FileProcessing, now time.Time) []Alert {
+	delivered := map[string]bool{}
+	for _, record := range records {
+		if record.FromParticipant != nil {
+			delivered[*record.FromParticipant] = true
+		}
+	}
+	alerts := []Alert{}
+	for _, participant := range m.Config.ExpectedParticipants {
+		if delivered[participant] {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Kind:        AlertMissingDelivery,
+			Message:     fmt.Sprintf("participant %s has not delivered a file since %s", participant, now.Add(-m.Config.Window).Format(time.RFC3339)),
+			Participant: participant,
+			RaisedAt:    now,
+		})
+	}
+	return alerts
+}
+
+// notify raises alert through every notifier that has not raised it yet, even when one of them fails,
+// and records the notifiers that raised it in alerted.
+func (m *Monitor) notify(ctx context.Context, alert Alert, alerted *raisedAlert) error {
+	var errs []error
+	for i, notifier := range m.Notifiers {
+		if alerted.notified[i] {
+			continue
+		}
+		err := notifier.Notify(ctx, alert)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", "Failed to raise alert", alert.Kind, err))
+			continue
+		}
+		alerted.notified[i] = true
+	}
+	return errors.Join(errs...)
+}
+
+// fileAlert creates an alert of kind for the file of record.
+func fileAlert(kind AlertKind, record *model.FileProcessing, now time.Time) Alert {
+	id := record.ID
+	alert := Alert{Kind: kind, FileProcessingID: &id, FileName: record.FileName, RaisedAt: now}
+	if record.FromParticipant != nil {
+		alert.Participant = *record.FromParticipant
+	}
+	return alert
+}
+
+// alertKey identifies the condition of an alert, which is its file, or the participant of a missing delivery.
+func alertKey(alert Alert) string {
+	if alert.FileProcessingID != nil {
+		return fmt.Sprintf("%s/%s", alert.Kind, alert.FileProcessingID)
+	}
+	return fmt.Sprintf("%s/%s", alert.Kind, alert.Participant)
+}
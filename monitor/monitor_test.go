@@ -0,0 +1,274 @@
+package monitor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/monitor"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+var now = time.Date(2005, 6, 9, 12, 0, 0, 0, time.UTC)
+
+type CheckTestCase struct {
+	Name     string
+	Record   model.FileProcessing
+	Failures []string
+	Expected []string
+}
+
+func TestMonitorCheck(t *testing.T) {
+	tests := []CheckTestCase{
+		{
+			Name:     "file that has stalled while processing",
+			Record:   fileProcessing("stalled.csv", repo.FileStatusProcessing, now.Add(-3*time.Hour), now.Add(-2*time.Hour)),
+			Expected: []string{"stalled_file: file stalled.csv has been processing since 2005-06-09T10:00:00Z"},
+		},
+		{
+			Name:     "file that has stalled before processing",
+			Record:   fileProcessing("received.csv", repo.FileStatusReceived, now.Add(-90*time.Minute), time.Time{}),
+			Expected: []string{"stalled_file: file received.csv has been received since 2005-06-09T10:30:00Z"},
+		},
+		{
+			Name:   "file that is processing within the timeout",
+			Record: fileProcessing("processing.csv", repo.FileStatusProcessing, now.Add(-3*time.Hour), now.Add(-30*time.Minute)),
+		},
+		{
+			Name:     "partial file",
+			Record:   fileProcessing("partial.csv", repo.FileStatusPartial, now.Add(-time.Hour), now.Add(-time.Hour)),
+			Failures: []string{"NEM1201010", "NEM1201009"},
+			Expected: []string{"failed_nmis: file partial.csv has 2 failed NMIs: NEM1201009, NEM1201010"},
+		},
+		{
+			Name:     "partial file before the window",
+			Record:   fileProcessing("partial.csv", repo.FileStatusPartial, now.Add(-25*time.Hour), now.Add(-25*time.Hour)),
+			Failures: []string{"NEM1201009"},
+		},
+		{
+			Name:     "failed file with failed NMIs",
+			Record:   fileProcessing("failed.csv", repo.FileStatusFailed, now.Add(-time.Hour), now.Add(-time.Hour)),
+			Failures: []string{"NEM1201009"},
+			Expected: []string{"failed_file: file failed.csv failed, with 1 failed NMIs: NEM1201009"},
+		},
+		{
+			Name:     "failed file",
+			Record:   fileProcessing("failed.csv", repo.FileStatusFailed, now.Add(-time.Hour), now.Add(-time.Hour)),
+			Expected: []string{"failed_file: file failed.csv failed"},
+		},
+		{
+			Name:   "completed file",
+			Record: fileProcessing("completed.csv", repo.FileStatusCompleted, now.Add(-time.Hour), now.Add(-time.Hour)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repository := repo.NewMemoryFileProcessingRepository()
+			record := tt.Record
+			err := repository.CreateFileProcessing(ctx, &record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, nmi := range tt.Failures {
+				err = repository.InsertFileProcessingFailures(ctx, []*model.FileProcessingFailures{{FileProcessingID: record.ID, Nmi: nmi, Errors: "failed"}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			notifier := &recordingNotifier{}
+			m := monitor.NewMonitor(repository, monitor.Config{}, notifier)
+
+			alerts, err := m.Check(ctx, now)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			expected := []string{}
+			if len(tt.Expected) > 0 {
+				expected = tt.Expected
+			}
+			if reflect.DeepEqual(expected, alertMessages(alerts)) != true {
+				t.Errorf("Expected %v, got %v instead", expected, alertMessages(alerts))
+			}
+			if reflect.DeepEqual(expected, alertMessages(notifier.alerts)) != true {
+				t.Errorf("Expected %v to be notified, got %v instead", expected, alertMessages(notifier.alerts))
+			}
+		})
+	}
+}
+
+type MissingDeliveryTestCase struct {
+	Name     string
+	Received time.Time
+	Expected []string
+}
+
+func TestMonitorMissingDeliveries(t *testing.T) {
+	tests := []MissingDeliveryTestCase{
+		{
+			Name:     "delivered within the window",
+			Received: now.Add(-23 * time.Hour),
+			Expected: []string{"missing_delivery: participant MISSINGDP has not delivered a file since 2005-06-08T12:00:00Z"},
+		},
+		{
+			Name:     "delivered before the window",
+			Received: now.Add(-25 * time.Hour),
+			Expected: []string{
+				"missing_delivery: participant UNITEDDP has not delivered a file since 2005-06-08T12:00:00Z",
+				"missing_delivery: participant MISSINGDP has not delivered a file since 2005-06-08T12:00:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repository := repo.NewMemoryFileProcessingRepository()
+			record := fileProcessing("delivered.csv", repo.FileStatusCompleted, tt.Received, tt.Received)
+			err := repository.CreateFileProcessing(ctx, &record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := monitor.NewMonitor(repository, monitor.Config{ExpectedParticipants: []string{"UNITEDDP", "MISSINGDP"}})
+
+			alerts, err := m.Check(ctx, now)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if reflect.DeepEqual(tt.Expected, alertMessages(alerts)) != true {
+				t.Errorf("Expected %v, got %v instead", tt.Expected, alertMessages(alerts))
+			}
+		})
+	}
+}
+
+func TestMonitorRaisesAlertsOnce(t *testing.T) {
+	ctx := context.Background()
+	repository := repo.NewMemoryFileProcessingRepository()
+	notifier := &recordingNotifier{}
+	m := monitor.NewMonitor(repository, monitor.Config{ExpectedParticipants: []string{"UNITEDDP"}}, notifier)
+
+	expected := []string{"missing_delivery: participant UNITEDDP has not delivered a file since 2005-06-08T12:00:00Z"}
+	alerts, err := m.Check(ctx, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if reflect.DeepEqual(expected, alertMessages(alerts)) != true {
+		t.Errorf("Expected %v, got %v instead", expected, alertMessages(alerts))
+	}
+
+	// the condition persists, but has already been alerted
+	alerts, err = m.Check(ctx, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected no alerts, got %v instead", alertMessages(alerts))
+	}
+
+	// the condition has persisted for longer than the window, so it is alerted again
+	alerts, err = m.Check(ctx, now.Add(25*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	expected = []string{"missing_delivery: participant UNITEDDP has not delivered a file since 2005-06-09T13:00:00Z"}
+	if reflect.DeepEqual(expected, alertMessages(alerts)) != true {
+		t.Errorf("Expected %v, got %v instead", expected, alertMessages(alerts))
+	}
+	if len(notifier.alerts) != 2 {
+		t.Errorf("Expected 2 alerts to be notified, got %v instead", alertMessages(notifier.alerts))
+	}
+}
+
+func TestMonitorRaisesFailedAlertsAgain(t *testing.T) {
+	ctx := context.Background()
+	repository := repo.NewMemoryFileProcessingRepository()
+	failing := &recordingNotifier{err: errors.New("notifier is down")}
+	working := &recordingNotifier{}
+	m := monitor.NewMonitor(repository, monitor.Config{ExpectedParticipants: []string{"UNITEDDP"}}, failing, working)
+
+	alerts, err := m.Check(ctx, now)
+	expectedErr := errors.New("Failed to raise alert missing_delivery: notifier is down")
+	if err == nil || err.Error() != expectedErr.Error() {
+		t.Errorf("Expected %v, got %v instead", expectedErr, err)
+	}
+	if len(alerts) != 1 || len(working.alerts) != 1 {
+		t.Errorf("Expected the alert to be raised through the working notifier, got %v instead", alertMessages(working.alerts))
+	}
+
+	// the alert is only raised again through the notifier that failed
+	failing.err = nil
+	alerts, err = m.Check(ctx, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(alerts) != 1 || len(failing.alerts) != 1 {
+		t.Errorf("Expected the alert to be raised again, got %v instead", alertMessages(failing.alerts))
+	}
+	if len(working.alerts) != 1 {
+		t.Errorf("Expected the alert not to be raised again through the working notifier, got %v instead", alertMessages(working.alerts))
+	}
+
+	// the alert has now been raised through every notifier
+	alerts, err = m.Check(ctx, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(alerts) != 0 || len(failing.alerts) != 1 || len(working.alerts) != 1 {
+		t.Errorf("Expected no alerts, got %v instead", alertMessages(alerts))
+	}
+}
+
+func TestMonitorRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	notifier := &recordingNotifier{cancel: cancel}
+	m := monitor.NewMonitor(repo.NewMemoryFileProcessingRepository(), monitor.Config{ExpectedParticipants: []string{"UNITEDDP"}}, notifier)
+
+	err := m.Run(ctx, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v instead", context.Canceled, err)
+	}
+	if len(notifier.alerts) != 1 {
+		t.Errorf("Expected the first check to run immediately, got %v instead", alertMessages(notifier.alerts))
+	}
+}
+
+// recordingNotifier keeps the alerts that it is notified of, and then fails with err, or cancels the monitor with cancel.
+type recordingNotifier struct {
+	alerts []monitor.Alert
+	err    error
+	cancel context.CancelFunc
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert monitor.Alert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	if n.cancel != nil {
+		n.cancel()
+	}
+	return nil
+}
+
+// fileProcessing creates a FileProcessing record of UNITEDDP, which is only started when startedAt is set.
+func fileProcessing(fileName string, status string, receivedAt time.Time, startedAt time.Time) model.FileProcessing {
+	participant := "UNITEDDP"
+	record := model.FileProcessing{FileName: fileName, Status: status, ReceivedAt: receivedAt, FromParticipant: &participant}
+	if !startedAt.IsZero() {
+		record.StartedAt = &startedAt
+	}
+	return record
+}
+
+func alertMessages(alerts []monitor.Alert) []string {
+	messages := []string{}
+	for _, alert := range alerts {
+		messages = append(messages, string(alert.Kind)+": "+alert.Message)
+	}
+	return messages
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultWebhookTimeout is how long a WebhookNotifier waits for its webhook to respond.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Notifier raises an Alert outside of the monitor, such as in a log, a webhook or a command.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier is a Notifier that writes alerts to Logger, or to the standard logger when Logger is nil.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("alert %s: %s", alert.Kind, alert.Message)
+	return nil
+}
+
+// WebhookNotifier is a Notifier that posts every alert as JSON to URL.
+// A response with a status other than 2xx is an error.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier that posts to url, and waits for DefaultWebhookTimeout for a response.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: DefaultWebhookTimeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with %s", n.URL, response.Status)
+	}
+	return nil
+}
+
+// CommandNotifier is a Notifier that runs the command Name with Args for every alert.
+// The alert is written as JSON to the standard input of the command, and its kind and message are also set
+// in the ALERT_KIND and ALERT_MESSAGE environment variables. A command that exits with a non-zero status is an error.
+type CommandNotifier struct {
+	Name string
+	Args []string
+}
+
+func (n *CommandNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, n.Name, n.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "ALERT_KIND="+string(alert.Kind), "ALERT_MESSAGE="+alert.Message)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command %s: %w: %s", n.Name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
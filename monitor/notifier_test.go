@@ -0,0 +1,145 @@
+package monitor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/ts33/energy-reading/monitor"
+)
+
+func sampleAlert() monitor.Alert {
+	id := uuid.MustParse("7d0e6bd4-5b3f-4d1a-9a3c-2d6a2f7f0c11")
+	return monitor.Alert{
+		Kind:             monitor.AlertFailedNmis,
+		Message:          "file partial.csv has 1 failed NMIs: NEM1201009",
+		FileProcessingID: &id,
+		FileName:         "partial.csv",
+		Participant:      "UNITEDDP",
+		Nmis:             []string{"NEM1201009"},
+		RaisedAt:         now,
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buffer bytes.Buffer
+	notifier := &monitor.LogNotifier{Logger: log.New(&buffer, "", 0)}
+
+	err := notifier.Notify(context.Background(), sampleAlert())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	expected := "alert failed_nmis: file partial.csv has 1 failed NMIs: NEM1201009\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q instead", expected, buffer.String())
+	}
+}
+
+type WebhookTestCase struct {
+	Name       string
+	StatusCode int
+	Err        string
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	tests := []WebhookTestCase{
+		{Name: "accepted", StatusCode: http.StatusOK},
+		{Name: "accepted without content", StatusCode: http.StatusNoContent},
+		{Name: "rejected", StatusCode: http.StatusInternalServerError, Err: "responded with 500 Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			var received monitor.Alert
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				err := json.NewDecoder(r.Body).Decode(&received)
+				if err != nil || r.Method != http.MethodPost {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.StatusCode)
+			}))
+			defer server.Close()
+
+			err := monitor.NewWebhookNotifier(server.URL).Notify(context.Background(), sampleAlert())
+			if tt.Err != "" {
+				expected := "webhook " + server.URL + " " + tt.Err
+				if err == nil || err.Error() != expected {
+					t.Errorf("Expected %v, got %v instead", expected, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+			if contentType != "application/json" {
+				t.Errorf("Expected application/json, got %v instead", contentType)
+			}
+			if reflect.DeepEqual(sampleAlert(), received) != true {
+				t.Errorf("Expected %+v, got %+v instead", sampleAlert(), received)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := monitor.NewWebhookNotifier(server.URL).Notify(context.Background(), sampleAlert())
+	if err == nil {
+		t.Errorf("Expected an error for a webhook that cannot be reached, got nil instead")
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	output := filepath.Join(t.TempDir(), "alert.json")
+	notifier := &monitor.CommandNotifier{Name: "sh", Args: []string{"-c", `cat > "$0" && test "$ALERT_KIND" = failed_nmis`, output}}
+
+	err := notifier.Notify(context.Background(), sampleAlert())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received monitor.Alert
+	err = json.Unmarshal(content, &received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(sampleAlert(), received) != true {
+		t.Errorf("Expected %+v, got %+v instead", sampleAlert(), received)
+	}
+}
+
+func TestCommandNotifierFails(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	notifier := &monitor.CommandNotifier{Name: "sh", Args: []string{"-c", `cat > /dev/null; echo "$ALERT_MESSAGE" >&2; exit 3`}}
+
+	err := notifier.Notify(context.Background(), sampleAlert())
+	expected := errors.New("command sh: exit status 3: file partial.csv has 1 failed NMIs: NEM1201009")
+	if err == nil || err.Error() != expected.Error() {
+		t.Errorf("Expected %v, got %v instead", expected, err)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("Expected an *exec.ExitError, got %T instead", err)
+	}
+}
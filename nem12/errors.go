@@ -0,0 +1,127 @@
+package nem12
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WholeRecord is the Field of a RecordLocation for an error that is about a record rather than one of its fields.
+const WholeRecord = -1
+
+// RecordLocation identifies the record, and the field of the record, of an NMI file that an error was raised for.
+type RecordLocation struct {
+	FileName        string
+	Line            int
+	RecordIndicator string
+	// Field is the index of the field within the record, where the record indicator is field 0.
+	Field int
+	// Record is the position of the record within its NMI block, where the 200 or 250 record is 0.
+	// It is used to find the Line of errors raised by a NmiBlockProcessor.
+	Record int
+}
+
+// location allows the RecordLocation of a ParseError or ValidationError to be found with errors.As.
+func (l *RecordLocation) location() *RecordLocation {
+	return l
+}
+
+// describe prefixes the message of err with the parts of the RecordLocation that are known.
+func (l *RecordLocation) describe(err error) string {
+	parts := []string{}
+	switch {
+	case l.FileName != "" && l.Line > 0:
+		parts = append(parts, fmt.Sprintf("%s:%d", l.FileName, l.Line))
+	case l.FileName != "":
+		parts = append(parts, l.FileName)
+	case l.Line > 0:
+		parts = append(parts, fmt.Sprintf("line %d", l.Line))
+	}
+	switch {
+	case l.RecordIndicator != "" && l.Field != WholeRecord:
+		parts = append(parts, fmt.Sprintf("record %s field %d", l.RecordIndicator, l.Field))
+	case l.RecordIndicator != "":
+		parts = append(parts, "record "+l.RecordIndicator)
+	case l.Field != WholeRecord:
+		parts = append(parts, fmt.Sprintf("field %d", l.Field))
+	}
+	parts = append(parts, err.Error())
+	return strings.Join(parts, ": ")
+}
+
+// ParseError is raised when a record, or a field of a record, cannot be parsed.
+type ParseError struct {
+	RecordLocation
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.describe(e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is raised when a record is parsed, but breaks a rule of the MDFF specification.
+type ValidationError struct {
+	RecordLocation
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.describe(e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError creates a ParseError for the field of a record with the given indicator.
+func newParseError(recordIndicator string, field int, err error) *ParseError {
+	return &ParseError{RecordLocation{RecordIndicator: recordIndicator, Field: field}, err}
+}
+
+// newValidationError creates a ValidationError for the field of a record with the given indicator.
+func newValidationError(recordIndicator string, field int, err error) *ValidationError {
+	return &ValidationError{RecordLocation{RecordIndicator: recordIndicator, Field: field}, err}
+}
+
+// locateRecord sets the position of the record within its NMI block on every ParseError and ValidationError in err.
+func locateRecord(err error, record int) error {
+	for _, e := range SplitErrors(err) {
+		var located interface{ location() *RecordLocation }
+		if errors.As(e, &located) {
+			located.location().Record = record
+		}
+	}
+	return err
+}
+
+// LocateFile sets the file name, and the line number of its record, on every ParseError and ValidationError in err.
+// lineNumbers holds the line number of every record of the NMI block, indexed by RecordLocation.Record,
+// so that the errors of a NmiBlockProcessor can be located within the file that the NMI block was read from.
+func LocateFile(err error, fileName string, lineNumbers []int) error {
+	for _, e := range SplitErrors(err) {
+		var located interface{ location() *RecordLocation }
+		if errors.As(e, &located) {
+			location := located.location()
+			location.FileName = fileName
+			if location.Record >= 0 && location.Record < len(lineNumbers) {
+				location.Line = lineNumbers[location.Record]
+			}
+		}
+	}
+	return err
+}
+
+// SplitErrors returns the list of errors that were joined with errors.Join, or err by itself.
+func SplitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
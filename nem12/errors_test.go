@@ -0,0 +1,47 @@
+package nem12_test
+
+import (
+	"errors"
+	"testing"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/nem12"
+)
+
+func TestProcessNmiBlockCollectsErrors(t *testing.T) {
+	dataStream := &model.DataStreams{
+		Nmi:            "NEM1201009",
+		NmiSuffix:      "E1",
+		Uom:            "kWh",
+		IntervalLength: 30,
+	}
+	nmiBlockRecords := []string{
+		buildIntervalRecord("20050301", "abc", 48, "A"),
+		buildIntervalRecord("20050302", "0.125", 48, "A"),
+		"400,1,49,A,,",
+		"500,O,S01009,2005031012,",
+	}
+
+	_, err := nem12.ProcessNmiBlock(nmiBlockRecords, dataStream)
+	expected := "record 300 field 2: Failed to parse consumption value to decimal: decimal.Parse: parsing \"abc\": invalid syntax\n" +
+		"record 400: interval event covers intervals 1 to 49, expected intervals within 1 to 48\n" +
+		"record 500 field 3: Failed to parse read date time: parsing time \"2005031012\" as \"20060102150405\": cannot parse \"\" as \"04\""
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected err %v, got %v instead", expected, err)
+	}
+
+	// every error records the position of its record within the block
+	records := []int{}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var validationErr *nem12.ValidationError
+		var parseErr *nem12.ParseError
+		if errors.As(e, &validationErr) {
+			records = append(records, validationErr.Record)
+		} else if errors.As(e, &parseErr) {
+			records = append(records, parseErr.Record)
+		}
+	}
+	if len(records) != 3 || records[0] != 1 || records[1] != 3 || records[2] != 4 {
+		t.Errorf("Expected records [1 3 4], got %v instead", records)
+	}
+}
@@ -0,0 +1,499 @@
+// Package nem12 parses the records of NEM12 and NEM13 meter data files (MDFF) into the models of the datastore.
+// It reads the records of a file with RecordReader, checks their nesting with RecordSequence,
+// and processes every NMI block with a NmiBlockProcessor, such as ProcessNem12Block or ProcessNem13Block.
+// Errors are raised as a ParseError or ValidationError, with the RecordLocation that they were raised for.
+package nem12
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+)
+
+const (
+	RecordIndicator_100    = "100"
+	RecordIndicator_200    = "200"
+	RecordIndicator_250    = "250"
+	RecordIndicator_300    = "300"
+	RecordIndicator_400    = "400"
+	RecordIndicator_500    = "500"
+	RecordIndicator_550    = "550"
+	RecordIndicator_900    = "900"
+	RecordTimestampLayout  = "20060102"
+	RecordDateTimeLayout   = "20060102150405"
+	HeaderDateTimeLayout   = "200601021504"
+	VersionHeader_NEM12    = "NEM12"
+	VersionHeader_NEM13    = "NEM13"
+	MinutesPerDay          = 24 * 60
+	QualityMethod_Variable = "V"
+)
+
+// PrecisionRule is the number of decimal places that meter reading values are kept to, and how they are rounded to it.
+// A value is stored in its canonical unit with DecimalPlaces decimal places, unless the unit of the file is smaller,
+// in which case it keeps DecimalPlaces decimal places of that unit. With 3 DecimalPlaces, a kWh or MWh value is stored
+// with 3 decimal places of kWh, while a Wh value is stored with 6 decimal places of kWh, which are 3 decimal places of Wh.
+type PrecisionRule struct {
+	DecimalPlaces int32
+	Rounding      decimal.RoundingMode
+}
+
+// Apply rounds value to the DecimalPlaces of the PrecisionRule.
+func (p PrecisionRule) Apply(value decimal.Decimal) decimal.Decimal {
+	return value.Round(p.DecimalPlaces, p.Rounding)
+}
+
+// Convert converts value exactly into a unit that is 10^-exponent times larger, such as Wh into kWh for -3,
+// and rounds it to DecimalPlaces, or to DecimalPlaces - exponent when the unit of value is smaller,
+// so that a digit that is kept in either unit is never lost.
+func (p PrecisionRule) Convert(value decimal.Decimal, exponent int32) decimal.Decimal {
+	places := p.DecimalPlaces + max(-exponent, 0)
+	return value.Shift(exponent).Round(places, p.Rounding)
+}
+
+// DefaultPrecisionRule returns the PrecisionRule that ProcessNmiBlock and ParseAccumulationRecord convert meter reading values with.
+// The MDFF specification allows up to 3 decimal places for interval values.
+// Another rule is used by calling the methods of the same name on a PrecisionRule.
+func DefaultPrecisionRule() PrecisionRule {
+	return PrecisionRule{DecimalPlaces: 3, Rounding: decimal.RoundHalfUp}
+}
+
+// ValidIntervalLengths contains the interval lengths (in minutes) that a NMI 200 record is allowed to specify.
+var ValidIntervalLengths = map[int32]bool{5: true, 15: true, 30: true}
+
+// NmiResultsParams contains the models of a NMI block that are ready to be inserted into the datastore.
+type NmiResultsParams struct {
+	DataStreams          []*model.DataStreams
+	MeterReadings        []*model.MeterReadings
+	IntervalReadings     []*model.IntervalReadings
+	B2bDetails           []*model.B2bDetails
+	AccumulationReadings []*model.AccumulationReadings
+}
+
+// NmiBlockProcessor processes the records of a NMI block into models that are ready to be inserted into the datastore.
+type NmiBlockProcessor func(nmiDataDetailsRecord string, nmiBlockRecords []string) (NmiResultsParams, error)
+
+// ParseNmiHeader creates a FileHeaders model object from a NMI 100 record.
+// The VersionHeader must be one of NEM12 or NEM13.
+func ParseNmiHeader(headerRecord string) (header *model.FileHeaders, err error) {
+	splitLine := strings.Split(headerRecord, ",")
+	if len(splitLine) < 5 {
+		return nil, newValidationError(RecordIndicator_100, WholeRecord, errors.New("header record does not have enough values"))
+	}
+	versionHeader := splitLine[1]
+	if versionHeader != VersionHeader_NEM12 && versionHeader != VersionHeader_NEM13 {
+		return nil, newValidationError(RecordIndicator_100, 1, fmt.Errorf("version header %s is not supported", versionHeader))
+	}
+	dateTime, err := time.Parse(HeaderDateTimeLayout, splitLine[2])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_100, 2, fmt.Errorf("%s: %w", "Failed to parse header date time", err))
+	}
+	if splitLine[3] == "" {
+		return nil, newValidationError(RecordIndicator_100, 3, errors.New("header record does not have a from participant"))
+	}
+	if splitLine[4] == "" {
+		return nil, newValidationError(RecordIndicator_100, 4, errors.New("header record does not have a to participant"))
+	}
+
+	return &model.FileHeaders{
+		VersionHeader:   versionHeader,
+		DateTime:        dateTime,
+		FromParticipant: splitLine[3],
+		ToParticipant:   splitLine[4],
+	}, nil
+}
+
+// ProcessNem12Block is the NmiBlockProcessor for NEM12 files, which processes a NMI 200 record and its NMI 300, 400 and 500 records
+// with the DefaultPrecisionRule.
+func ProcessNem12Block(nmiDataDetailsRecord string, nmiBlockRecords []string) (NmiResultsParams, error) {
+	return DefaultPrecisionRule().ProcessNem12Block(nmiDataDetailsRecord, nmiBlockRecords)
+}
+
+// ProcessNem12Block is the NmiBlockProcessor for NEM12 files that converts meter reading values with the PrecisionRule p.
+func (p PrecisionRule) ProcessNem12Block(nmiDataDetailsRecord string, nmiBlockRecords []string) (NmiResultsParams, error) {
+	dataStream, err := ParseNmiDataDetails(nmiDataDetailsRecord)
+	if err != nil {
+		return NmiResultsParams{}, err
+	}
+	return p.ProcessNmiBlock(nmiBlockRecords, dataStream)
+}
+
+// ParseNmiDataDetails creates a DataStreams model object from a NMI 200 record.
+// The Nmi is validated with ParseNmi, and the IntervalLength is validated against ValidIntervalLengths.
+func ParseNmiDataDetails(nmiDataDetailsRecord string) (dataStream *model.DataStreams, err error) {
+	splitLine := strings.Split(nmiDataDetailsRecord, ",")
+	if len(splitLine) < 10 {
+		return nil, newValidationError(RecordIndicator_200, WholeRecord, errors.New("nmi data details record does not have enough values"))
+	}
+	nmi, err := ParseNmi(splitLine[1])
+	if err != nil {
+		return nil, newValidationError(RecordIndicator_200, 1, err)
+	}
+	if splitLine[2] == "" {
+		return nil, newValidationError(RecordIndicator_200, 2, errors.New("nmi data details record does not have a nmi configuration"))
+	}
+	if splitLine[4] == "" {
+		return nil, newValidationError(RecordIndicator_200, 4, errors.New("nmi data details record does not have a nmi suffix"))
+	}
+	if splitLine[7] == "" {
+		return nil, newValidationError(RecordIndicator_200, 7, errors.New("nmi data details record does not have a unit of measure"))
+	}
+	_, err = ParseUnitOfMeasure(splitLine[7])
+	if err != nil {
+		return nil, newValidationError(RecordIndicator_200, 7, err)
+	}
+	intervalLength, err := strconv.ParseInt(splitLine[8], 10, 32)
+	if err != nil {
+		return nil, newParseError(RecordIndicator_200, 8, fmt.Errorf("%s: %w", "Failed to parse interval length", err))
+	}
+	if !ValidIntervalLengths[int32(intervalLength)] {
+		return nil, newValidationError(RecordIndicator_200, 8, fmt.Errorf("interval length %d is not supported", intervalLength))
+	}
+	nextScheduledReadDate, err := parseOptionalDate(splitLine[9])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_200, 9, fmt.Errorf("%s: %w", "Failed to parse next scheduled read date", err))
+	}
+
+	return &model.DataStreams{
+		Nmi:                     nmi,
+		NmiConfiguration:        splitLine[2],
+		RegisterID:              optionalString(splitLine[3]),
+		NmiSuffix:               splitLine[4],
+		MdmDataStreamIdentifier: optionalString(splitLine[5]),
+		MeterSerialNumber:       optionalString(splitLine[6]),
+		Uom:                     splitLine[7],
+		IntervalLength:          int32(intervalLength),
+		NextScheduledReadDate:   nextScheduledReadDate,
+	}, nil
+}
+
+// ProcessNmiBlock creates a MeterReadings model object for each NMI 300 record received,
+// along with an IntervalReadings model object for every interval value in the NMI 300 record.
+// Each NMI 300 record is expected to hold one interval value for every IntervalLength minutes of the day,
+// followed by the QualityMethod, ReasonCode, ReasonDescription, UpdateDateTime and MSATSLoadDateTime fields.
+// NMI 400 records override the quality of the intervals of the NMI 300 record that they follow, and must cover
+// every interval of a NMI 300 record with a V quality method. They can also follow a NMI 300 record with any other
+// quality method, such as an A day with reason codes 79, 89 or 61, without covering every interval.
+// Each NMI 500 record creates a B2bDetails model object.
+// Interval values are converted from the Uom of the data stream into its canonical unit.
+// Every record of the block is processed, and the errors of all the records that failed are returned joined together.
+// Interval values are converted with the DefaultPrecisionRule.
+func ProcessNmiBlock(nmiBlockRecords []string, dataStream *model.DataStreams) (results NmiResultsParams, err error) {
+	return DefaultPrecisionRule().ProcessNmiBlock(nmiBlockRecords, dataStream)
+}
+
+// ProcessNmiBlock processes the NMI 300, 400 and 500 records of the NMI block of dataStream in the same way as the
+// ProcessNmiBlock function, with interval values converted with the PrecisionRule p.
+func (p PrecisionRule) ProcessNmiBlock(nmiBlockRecords []string, dataStream *model.DataStreams) (results NmiResultsParams, err error) {
+	results = NmiResultsParams{
+		DataStreams:      []*model.DataStreams{dataStream},
+		MeterReadings:    []*model.MeterReadings{},
+		IntervalReadings: []*model.IntervalReadings{},
+		B2bDetails:       []*model.B2bDetails{},
+	}
+	if !ValidIntervalLengths[dataStream.IntervalLength] {
+		return results, newValidationError(RecordIndicator_200, 8, fmt.Errorf("interval length %d is not supported", dataStream.IntervalLength))
+	}
+	unit, err := ParseUnitOfMeasure(dataStream.Uom)
+	if err != nil {
+		return results, newValidationError(RecordIndicator_200, 7, err)
+	}
+
+	errs := []error{}
+	// the interval readings of the latest NMI 300 record, which the NMI 400 records that follow it apply to
+	var dayIntervals []*model.IntervalReadings
+	var dayQualityMethod string
+	var dayIntervalsCovered []bool
+	// the position of the latest NMI 300 record in the block, and whether it or one of its NMI 400 records failed
+	var dayRecord int
+	var dayFailed bool
+	validateDay := func() {
+		if dayFailed {
+			return
+		}
+		err := validateIntervalEventCoverage(dayQualityMethod, dayIntervalsCovered)
+		if err != nil {
+			errs = append(errs, locateRecord(err, dayRecord))
+		}
+	}
+
+	for i, nmiBlockRecord := range nmiBlockRecords {
+		// the 200 record is record 0 of the block
+		record := i + 1
+		splitLine := strings.Split(nmiBlockRecord, ",")
+
+		switch splitLine[0] {
+		case RecordIndicator_300:
+			validateDay()
+			dayIntervals, dayQualityMethod, dayIntervalsCovered = nil, "", nil
+			dayRecord, dayFailed = record, false
+			meterReading, intervalReadings, err := processIntervalDataRecord(splitLine, dataStream, unit, p)
+			if err != nil {
+				errs = append(errs, locateRecord(err, record))
+				dayFailed = true
+				continue
+			}
+			dayIntervals = intervalReadings
+			// the QualityMethod is the fifth last field of a NMI 300 record
+			dayQualityMethod = splitLine[len(splitLine)-5]
+			dayIntervalsCovered = make([]bool, len(dayIntervals))
+			results.MeterReadings = append(results.MeterReadings, meterReading)
+			results.IntervalReadings = append(results.IntervalReadings, dayIntervals...)
+		case RecordIndicator_400:
+			// the NMI 300 record has already failed, so its interval events cannot be checked
+			if dayFailed {
+				continue
+			}
+			if dayIntervals == nil {
+				err = newValidationError(RecordIndicator_400, WholeRecord, errors.New("interval event record does not follow a meter reading"))
+			} else {
+				err = applyIntervalEventRecord(splitLine, dayIntervals, dayIntervalsCovered)
+			}
+			if err != nil {
+				errs = append(errs, locateRecord(err, record))
+				dayFailed = true
+			}
+		case RecordIndicator_500:
+			validateDay()
+			// interval events cannot follow a NMI 500 record
+			dayIntervals, dayQualityMethod, dayIntervalsCovered = nil, "", nil
+			dayFailed = false
+			b2bDetails, err := processB2bDetailsRecord(splitLine, dataStream)
+			if err != nil {
+				errs = append(errs, locateRecord(err, record))
+				continue
+			}
+			results.B2bDetails = append(results.B2bDetails, b2bDetails)
+		default:
+			err = newValidationError(splitLine[0], WholeRecord, errors.New("not allowed in a nmi block"))
+			errs = append(errs, locateRecord(err, record))
+		}
+	}
+
+	validateDay()
+	return results, errors.Join(errs...)
+}
+
+// processIntervalDataRecord creates the MeterReadings model object and IntervalReadings model objects for a NMI 300 record.
+func processIntervalDataRecord(splitLine []string, dataStream *model.DataStreams, unit UnitOfMeasure, precision PrecisionRule) (meterReading *model.MeterReadings, intervalReadings []*model.IntervalReadings, err error) {
+	intervalLength := int(dataStream.IntervalLength)
+	numIntervals := MinutesPerDay / intervalLength
+
+	// record indicator and interval date, the interval values, and the 5 trailing fields
+	if len(splitLine) < numIntervals+7 {
+		return nil, nil, newValidationError(RecordIndicator_300, WholeRecord, errors.New("meter reading does not have enough values"))
+	}
+	if len(splitLine) > numIntervals+7 {
+		return nil, nil, newValidationError(RecordIndicator_300, WholeRecord, errors.New("meter reading has too many values"))
+	}
+	timestamp, err := time.Parse(RecordTimestampLayout, splitLine[1])
+	if err != nil {
+		return nil, nil, newParseError(RecordIndicator_300, 1, fmt.Errorf("%s: %w", "Failed to parse time value", err))
+	}
+	values, err := parseConsumptionValues(splitLine, 2, numIntervals, unit, precision)
+	if err != nil {
+		return nil, nil, err
+	}
+	consumption, err := sumConsumptionValues(values)
+	if err != nil {
+		return nil, nil, newValidationError(RecordIndicator_300, WholeRecord, fmt.Errorf("%s: %w", "Failed to sum consumption values", err))
+	}
+	qualityMethod := splitLine[2+numIntervals]
+	reasonCode, err := parseReasonCode(splitLine[3+numIntervals])
+	if err != nil {
+		return nil, nil, newParseError(RecordIndicator_300, 3+numIntervals, err)
+	}
+	reasonDescription := optionalString(splitLine[4+numIntervals])
+	updateDateTime, err := time.Parse(RecordDateTimeLayout, splitLine[5+numIntervals])
+	if err != nil {
+		return nil, nil, newParseError(RecordIndicator_300, 5+numIntervals, fmt.Errorf("%s: %w", "Failed to parse update date time", err))
+	}
+	msatsLoadDateTime, err := parseOptionalDateTime(splitLine[6+numIntervals])
+	if err != nil {
+		return nil, nil, newParseError(RecordIndicator_300, 6+numIntervals, fmt.Errorf("%s: %w", "Failed to parse MSATS load date time", err))
+	}
+
+	meterReading = &model.MeterReadings{
+		Nmi:               dataStream.Nmi,
+		NmiSuffix:         dataStream.NmiSuffix,
+		Timestamp:         timestamp,
+		Consumption:       consumption,
+		Uom:               unit.Canonical,
+		OriginalUom:       unit.Original,
+		QualityMethod:     qualityMethod,
+		ReasonCode:        reasonCode,
+		ReasonDescription: reasonDescription,
+		UpdateDateTime:    updateDateTime,
+		MsatsLoadDateTime: msatsLoadDateTime,
+	}
+
+	intervalReadings = make([]*model.IntervalReadings, 0, numIntervals)
+	for i, value := range values {
+		intervalReading := &model.IntervalReadings{
+			Nmi:               dataStream.Nmi,
+			NmiSuffix:         dataStream.NmiSuffix,
+			IntervalStart:     timestamp.Add(time.Duration(i*intervalLength) * time.Minute),
+			Value:             value,
+			Uom:               unit.Canonical,
+			OriginalUom:       unit.Original,
+			Quality:           qualityMethod,
+			ReasonCode:        reasonCode,
+			ReasonDescription: reasonDescription,
+			UpdateDateTime:    updateDateTime,
+		}
+		intervalReadings = append(intervalReadings, intervalReading)
+	}
+	return meterReading, intervalReadings, nil
+}
+
+// applyIntervalEventRecord sets the quality of the intervals covered by a NMI 400 record,
+// and marks those intervals in intervalsCovered.
+func applyIntervalEventRecord(splitLine []string, intervalReadings []*model.IntervalReadings, intervalsCovered []bool) error {
+	if len(splitLine) < 6 {
+		return newValidationError(RecordIndicator_400, WholeRecord, errors.New("interval event does not have enough values"))
+	}
+	startInterval, err := strconv.Atoi(splitLine[1])
+	if err != nil {
+		return newParseError(RecordIndicator_400, 1, fmt.Errorf("%s: %w", "Failed to parse start interval", err))
+	}
+	endInterval, err := strconv.Atoi(splitLine[2])
+	if err != nil {
+		return newParseError(RecordIndicator_400, 2, fmt.Errorf("%s: %w", "Failed to parse end interval", err))
+	}
+	if startInterval < 1 || endInterval < startInterval || endInterval > len(intervalReadings) {
+		return newValidationError(RecordIndicator_400, WholeRecord, fmt.Errorf("interval event covers intervals %d to %d, expected intervals within 1 to %d", startInterval, endInterval, len(intervalReadings)))
+	}
+	qualityMethod := splitLine[3]
+	if qualityMethod == "" || qualityMethod == QualityMethod_Variable {
+		return newValidationError(RecordIndicator_400, 3, fmt.Errorf("interval event has invalid quality method %q", qualityMethod))
+	}
+	reasonCode, err := parseReasonCode(splitLine[4])
+	if err != nil {
+		return newParseError(RecordIndicator_400, 4, err)
+	}
+	reasonDescription := optionalString(splitLine[5])
+
+	// intervals are numbered from 1 in the NMI 400 record
+	for i := startInterval - 1; i < endInterval; i++ {
+		if intervalsCovered[i] {
+			return newValidationError(RecordIndicator_400, WholeRecord, fmt.Errorf("interval %d is covered by more than one interval event", i+1))
+		}
+		intervalsCovered[i] = true
+		intervalReadings[i].Quality = qualityMethod
+		intervalReadings[i].ReasonCode = reasonCode
+		intervalReadings[i].ReasonDescription = reasonDescription
+	}
+	return nil
+}
+
+// processB2bDetailsRecord creates the B2bDetails model object for a NMI 500 record.
+func processB2bDetailsRecord(splitLine []string, dataStream *model.DataStreams) (b2bDetails *model.B2bDetails, err error) {
+	if len(splitLine) < 5 {
+		return nil, newValidationError(RecordIndicator_500, WholeRecord, errors.New("b2b details does not have enough values"))
+	}
+	readDateTime, err := parseOptionalDateTime(splitLine[3])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_500, 3, fmt.Errorf("%s: %w", "Failed to parse read date time", err))
+	}
+	return &model.B2bDetails{
+		Nmi:             dataStream.Nmi,
+		NmiSuffix:       dataStream.NmiSuffix,
+		TransCode:       splitLine[1],
+		RetServiceOrder: splitLine[2],
+		ReadDateTime:    readDateTime,
+		IndexRead:       optionalString(splitLine[4]),
+	}, nil
+}
+
+// validateIntervalEventCoverage checks that every interval of a NMI 300 record with a V quality method
+// has been covered by a NMI 400 record.
+func validateIntervalEventCoverage(qualityMethod string, intervalsCovered []bool) error {
+	if qualityMethod != QualityMethod_Variable {
+		return nil
+	}
+	for i, covered := range intervalsCovered {
+		if !covered {
+			return newValidationError(RecordIndicator_300, WholeRecord, fmt.Errorf("interval %d of a meter reading with quality method V is not covered by an interval event", i+1))
+		}
+	}
+	return nil
+}
+
+// parseReasonCode parses an optional ReasonCode field.
+func parseReasonCode(field string) (*int32, error) {
+	if field == "" {
+		return nil, nil
+	}
+	reasonCode, err := strconv.ParseInt(field, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "Failed to parse reason code", err)
+	}
+	code := int32(reasonCode)
+	return &code, nil
+}
+
+// parseOptionalDate parses an optional date field in the RecordTimestampLayout.
+func parseOptionalDate(field string) (*time.Time, error) {
+	if field == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(RecordTimestampLayout, field)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// parseOptionalDateTime parses an optional date time field in the RecordDateTimeLayout.
+func parseOptionalDateTime(field string) (*time.Time, error) {
+	if field == "" {
+		return nil, nil
+	}
+	dateTime, err := time.Parse(RecordDateTimeLayout, field)
+	if err != nil {
+		return nil, err
+	}
+	return &dateTime, nil
+}
+
+// optionalString returns nil for an empty field, so that it is stored as NULL.
+func optionalString(field string) *string {
+	if field == "" {
+		return nil
+	}
+	return &field
+}
+
+// parseConsumptionValues parses the numIntervals decimal values of a NMI 300 record, starting from the field at index first,
+// with parseConsumptionValue.
+func parseConsumptionValues(splitLine []string, first int, numIntervals int, unit UnitOfMeasure, precision PrecisionRule) (values []decimal.Decimal, err error) {
+	values = make([]decimal.Decimal, 0, numIntervals)
+	for field := first; field < first+numIntervals; field++ {
+		val, err := parseConsumptionValue(splitLine[field], unit, precision)
+		if err != nil {
+			return nil, newParseError(RecordIndicator_300, field, fmt.Errorf("%s: %w", "Failed to parse consumption value to decimal", err))
+		}
+		values = append(values, val)
+	}
+	return values, nil
+}
+
+// parseConsumptionValue takes in a stringified decimal and parses it exactly, converting it into the canonical unit of the given unit
+// with precision.
+func parseConsumptionValue(number string, unit UnitOfMeasure, precision PrecisionRule) (value decimal.Decimal, err error) {
+	val, err := decimal.Parse(number)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return precision.Convert(val, unit.Exponent), nil
+}
+
+// sumConsumptionValues takes in a list of decimals of the same canonical unit and sums them up exactly.
+// The values have already been rounded once when they were parsed, so the sum is not rounded again.
+func sumConsumptionValues(values []decimal.Decimal) (sum decimal.Decimal, err error) {
+	return decimal.Sum(values)
+}
@@ -0,0 +1,1128 @@
+package nem12_test
+
+import (
+	"errors"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	"github.com/ts33/energy-reading/nem12"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// updateDateTime and msatsLoadDateTime are the trailing date times of every NMI 300 record in the test files
+var updateDateTime = time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC)
+var msatsLoadDateTime = time.Date(2005, time.March, 10, 18, 22, 4, 0, time.UTC)
+
+type ProcessNmiBlockTestCase struct {
+	Name                        string
+	ProcessNmiBlockTestInput    ProcessNmiBlockTestInput
+	ProcessNmiBlockTestExpected ProcessNmiBlockTestExpected
+}
+
+type ProcessNmiBlockTestInput struct {
+	NmiBlockRecords []string
+	DataStream      *model.DataStreams
+}
+
+type ProcessNmiBlockTestExpected struct {
+	MeterReadings       []*model.MeterReadings
+	NumIntervalReadings int
+	Err                 error
+}
+
+func TestProcessNmiBlock(t *testing.T) {
+	tests := []ProcessNmiBlockTestCase{
+		{
+			Name: "Happy Case - Process Blocks",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,20050301,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,A,,,20050310121004,20050310182204",
+					"300,20050302,0,0,0,0,0,0,0,0,0,0,0,0,0.235,0.567,0.890,1.123,1.345,1.567,1.543,1.234,0.987,1.123,0.876,1.345,1.145,1.173,1.265,0.987,0.678,0.998,0.768,0.954,0.876,0.845,0.932,0.786,0.999,0.879,0.777,0.578,0.709,0.772,0.625,0.653,0.543,0.599,0.432,0.432,A,,,20050310121004,20050310182204",
+					"300,20050303,0,0,0,0,0,0,0,0,0,0,0,0,0.261,0.310,0.678,0.934,1.211,1.134,1.423,1.370,0.988,1.207,0.890,1.320,1.130,1.913,1.180,0.950,0.746,0.635,0.956,0.887,0.560,0.700,0.788,0.668,0.543,0.738,0.802,0.490,0.598,0.809,0.520,0.670,0.570,0.600,0.289,0.321,A,,,20050310121004,20050310182204",
+					"300,20050304,0,0,0,0,0,0,0,0,0,0,0,0,0.335,0.667,0.790,1.023,1.145,1.777,1.563,1.344,1.087,1.453,0.996,1.125,1.435,1.263,1.085,1.487,1.278,0.768,0.878,0.754,0.476,1.045,1.132,0.896,0.879,0.679,0.887,0.784,0.954,0.712,0.599,0.593,0.674,0.799,0.232,0.612,A,,,20050310121004,20050310182204",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("31.444"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 2, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("32.24"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 3, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("29.789"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 4, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("34.206"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 192,
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Happy Case - Empty Block",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           nil,
+			},
+		},
+		{
+			Name: "Happy Case - 15 Minute Interval Length",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.125", 96, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 15,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("12"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 96,
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Happy Case - 5 Minute Interval Length",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.010", 288, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 5,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("2.88"),
+						Uom:               "kWh",
+						OriginalUom:       "kWh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 288,
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Happy Case - Wh Converted To kWh",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "250", 48, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "Wh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("12"),
+						Uom:               "kWh",
+						OriginalUom:       "Wh",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 48,
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Happy Case - MWh Converted To kWh",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.0005", 48, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "MWH",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{
+					{
+						Nmi:               "NEM1201009",
+						NmiSuffix:         "E1",
+						Timestamp:         time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+						Consumption:       decimal.MustParse("24"),
+						Uom:               "kWh",
+						OriginalUom:       "MWH",
+						QualityMethod:     "A",
+						UpdateDateTime:    updateDateTime,
+						MsatsLoadDateTime: &msatsLoadDateTime,
+					},
+				},
+				NumIntervalReadings: 48,
+				Err:                 nil,
+			},
+		},
+		{
+			Name: "Error Case - Unit Of Measure Not Supported",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.125", 48, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "therm",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 200 field 7: unit of measure therm is not supported"),
+			},
+		},
+		{
+			Name: "Error Case - Unit Of Measure Not Energy",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.125", 48, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kW",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 200 field 7: unit of measure kW is not an energy unit"),
+			},
+		},
+		{
+			Name: "Error Case - Interval Values Do Not Match Interval Length",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.125", 96, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300: meter reading has too many values"),
+			},
+		},
+		{
+			Name: "Error Case - Interval Length Not Supported",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					buildIntervalRecord("20050301", "0.125", 72, "A"),
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 20,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 200 field 8: interval length 20 is not supported"),
+			},
+		},
+		{
+			Name: "Error Case - Meter Record Incomplete",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,20050301,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300: meter reading does not have enough values"),
+			},
+		},
+		{
+			Name: "Error Case - Timestamp wrong format",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,2005030101,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,A,,,20050310121004,20050310182204",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300 field 1: Failed to parse time value: parsing time \"2005030101\": extra text: \"01\""),
+			},
+		},
+		{
+			Name: "Error Case - Update Date Time Missing",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,20050301,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,A,,,,20050310182204",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300 field 53: Failed to parse update date time: parsing time \"\" as \"20060102150405\": cannot parse \"\" as \"2006\""),
+			},
+		},
+		{
+			Name: "Error Case - Reason Code Wrong",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,20050301,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,S,abc,,20050310121004,20050310182204",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300 field 51: Failed to parse reason code: strconv.ParseInt: parsing \"abc\": invalid syntax"),
+			},
+		},
+		{
+			Name: "Error Case - Consumption Block Values Wrong",
+			ProcessNmiBlockTestInput: ProcessNmiBlockTestInput{
+				NmiBlockRecords: []string{
+					"300,20050301,abc,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,A,,,20050310121004,20050310182204",
+				},
+				DataStream: &model.DataStreams{
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					Uom:            "kWh",
+					IntervalLength: 30,
+				},
+			},
+			ProcessNmiBlockTestExpected: ProcessNmiBlockTestExpected{
+				MeterReadings: []*model.MeterReadings{},
+				Err:           errors.New("record 300 field 2: Failed to parse consumption value to decimal: decimal.Parse: parsing \"abc\": invalid syntax"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			results, err := nem12.ProcessNmiBlock(
+				tt.ProcessNmiBlockTestInput.NmiBlockRecords,
+				tt.ProcessNmiBlockTestInput.DataStream,
+			)
+			result := results.MeterReadings
+			intervalReadings := results.IntervalReadings
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.ProcessNmiBlockTestExpected.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				}
+				if tt.ProcessNmiBlockTestExpected.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.ProcessNmiBlockTestExpected.Err, err)
+				}
+			}
+
+			// assert result is correct
+			if len(result) != len(tt.ProcessNmiBlockTestExpected.MeterReadings) {
+				t.Errorf("Expected %+v number of readings, got %+v number of readings instead", len(tt.ProcessNmiBlockTestExpected.MeterReadings), len(result))
+			}
+			if len(intervalReadings) != tt.ProcessNmiBlockTestExpected.NumIntervalReadings {
+				t.Errorf("Expected %+v number of interval readings, got %+v number of interval readings instead", tt.ProcessNmiBlockTestExpected.NumIntervalReadings, len(intervalReadings))
+			}
+			for i, meterReading := range result {
+				if reflect.DeepEqual(tt.ProcessNmiBlockTestExpected.MeterReadings[i], meterReading) != true {
+					t.Errorf("Expected %+v, got %+v instead", tt.ProcessNmiBlockTestExpected.MeterReadings[i], meterReading)
+				}
+			}
+		})
+	}
+}
+
+type ParseNmiHeaderTestCase struct {
+	Name   string
+	Record string
+	Header *model.FileHeaders
+	Err    error
+}
+
+func TestParseNmiHeader(t *testing.T) {
+	tests := []ParseNmiHeaderTestCase{
+		{
+			Name:   "Happy Case - NEM12 header",
+			Record: "100,NEM12,200506081149,UNITEDDP,NEMMCO",
+			Header: &model.FileHeaders{
+				VersionHeader:   "NEM12",
+				DateTime:        time.Date(2005, time.June, 8, 11, 49, 0, 0, time.UTC),
+				FromParticipant: "UNITEDDP",
+				ToParticipant:   "NEMMCO",
+			},
+			Err: nil,
+		},
+		{
+			Name:   "Happy Case - NEM13 header",
+			Record: "100,NEM13,200506081149,UNITEDDP,NEMMCO",
+			Header: &model.FileHeaders{
+				VersionHeader:   "NEM13",
+				DateTime:        time.Date(2005, time.June, 8, 11, 49, 0, 0, time.UTC),
+				FromParticipant: "UNITEDDP",
+				ToParticipant:   "NEMMCO",
+			},
+			Err: nil,
+		},
+		{
+			Name:   "Error Case - version header not supported",
+			Record: "100,NEM14,200506081149,UNITEDDP,NEMMCO",
+			Header: nil,
+			Err:    errors.New("record 100 field 1: version header NEM14 is not supported"),
+		},
+		{
+			Name:   "Error Case - date time wrong format",
+			Record: "100,NEM12,20050608,UNITEDDP,NEMMCO",
+			Header: nil,
+			Err:    errors.New("record 100 field 2: Failed to parse header date time: parsing time \"20050608\" as \"200601021504\": cannot parse \"\" as \"15\""),
+		},
+		{
+			Name:   "Error Case - from participant missing",
+			Record: "100,NEM12,200506081149,,NEMMCO",
+			Header: nil,
+			Err:    errors.New("record 100 field 3: header record does not have a from participant"),
+		},
+		{
+			Name:   "Error Case - to participant missing",
+			Record: "100,NEM12,200506081149,UNITEDDP,",
+			Header: nil,
+			Err:    errors.New("record 100 field 4: header record does not have a to participant"),
+		},
+		{
+			Name:   "Error Case - record incomplete",
+			Record: "100,NEM12,200506081149",
+			Header: nil,
+			Err:    errors.New("record 100: header record does not have enough values"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			header, err := nem12.ParseNmiHeader(tt.Record)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+			} else if tt.Err != nil {
+				t.Errorf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.Header, header) != true {
+				t.Errorf("Expected %+v, got %+v instead", tt.Header, header)
+			}
+		})
+	}
+}
+
+type ParseNmiDataDetailsTestCase struct {
+	Name       string
+	Record     string
+	DataStream *model.DataStreams
+	Err        error
+}
+
+func TestParseNmiDataDetails(t *testing.T) {
+	registerID := "1"
+	mdmDataStreamIdentifier := "N1"
+	meterSerialNumber := "01009"
+	nextScheduledReadDate := time.Date(2005, time.June, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []ParseNmiDataDetailsTestCase{
+		{
+			Name:   "Happy Case - 30 minute interval",
+			Record: "200,NEM1201009,E1E2,1,E1,N1,01009,kWh,30,20050610",
+			DataStream: &model.DataStreams{
+				Nmi:                     "NEM1201009",
+				NmiConfiguration:        "E1E2",
+				RegisterID:              &registerID,
+				NmiSuffix:               "E1",
+				MdmDataStreamIdentifier: &mdmDataStreamIdentifier,
+				MeterSerialNumber:       &meterSerialNumber,
+				Uom:                     "kWh",
+				IntervalLength:          30,
+				NextScheduledReadDate:   &nextScheduledReadDate,
+			},
+			Err: nil,
+		},
+		{
+			Name:   "Happy Case - 5 minute interval without optional fields",
+			Record: "200,NEM1201010,E1E2,,E2,,,kWh,5,",
+			DataStream: &model.DataStreams{
+				Nmi:              "NEM1201010",
+				NmiConfiguration: "E1E2",
+				NmiSuffix:        "E2",
+				Uom:              "kWh",
+				IntervalLength:   5,
+			},
+			Err: nil,
+		},
+		{
+			Name:       "Error Case - unsupported interval",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,kWh,60,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 8: interval length 60 is not supported"),
+		},
+		{
+			Name:       "Error Case - interval not a number",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,kWh,abc,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 8: Failed to parse interval length: strconv.ParseInt: parsing \"abc\": invalid syntax"),
+		},
+		{
+			Name:       "Error Case - nmi suffix missing",
+			Record:     "200,NEM1201009,E1E2,1,,N1,01009,kWh,30,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 4: nmi data details record does not have a nmi suffix"),
+		},
+		{
+			Name:       "Error Case - nmi configuration missing",
+			Record:     "200,NEM1201009,,1,E1,N1,01009,kWh,30,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 2: nmi data details record does not have a nmi configuration"),
+		},
+		{
+			Name:       "Error Case - unit of measure missing",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,,30,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 7: nmi data details record does not have a unit of measure"),
+		},
+		{
+			Name:       "Error Case - unit of measure not supported",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,gal,30,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 7: unit of measure gal is not supported"),
+		},
+		{
+			Name:       "Error Case - unit of measure not energy",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,kVA,30,20050610",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 7: unit of measure kVA is not an energy unit"),
+		},
+		{
+			Name:       "Error Case - next scheduled read date wrong format",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,kWh,30,2005061",
+			DataStream: nil,
+			Err:        errors.New("record 200 field 9: Failed to parse next scheduled read date: parsing time \"2005061\" as \"20060102\": cannot parse \"1\" as \"02\""),
+		},
+		{
+			Name:       "Error Case - record incomplete",
+			Record:     "200,NEM1201009,E1E2,1,E1,N1,01009,kWh",
+			DataStream: nil,
+			Err:        errors.New("record 200: nmi data details record does not have enough values"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			dataStream, err := nem12.ParseNmiDataDetails(tt.Record)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+			} else if tt.Err != nil {
+				t.Errorf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.DataStream, dataStream) != true {
+				t.Errorf("Expected %+v, got %+v instead", tt.DataStream, dataStream)
+			}
+		})
+	}
+}
+
+func TestProcessNmiBlockIntervalReadings(t *testing.T) {
+	dataStream := &model.DataStreams{
+		Nmi:            "NEM1201009",
+		NmiSuffix:      "E1",
+		Uom:            "kWh",
+		IntervalLength: 30,
+	}
+	nmiBlockRecords := []string{
+		"300,20050301,0,0,0,0,0,0,0,0,0,0,0,0,0.461,0.810,0.568,1.234,1.353,1.507,1.344,1.773,0.848,1.271,0.895,1.327,1.013,1.793,0.988,0.985,0.876,0.555,0.760,0.938,0.566,0.512,0.970,0.760,0.731,0.615,0.886,0.531,0.774,0.712,0.598,0.670,0.587,0.657,0.345,0.231,A,,,20050310121004,20050310182204",
+	}
+
+	results, err := nem12.ProcessNmiBlock(nmiBlockRecords, dataStream)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	intervalReadings := results.IntervalReadings
+	if len(intervalReadings) != 48 {
+		t.Fatalf("Expected 48 interval readings, got %v instead", len(intervalReadings))
+	}
+
+	expected := map[int]*model.IntervalReadings{
+		0: {
+			Nmi:            "NEM1201009",
+			NmiSuffix:      "E1",
+			IntervalStart:  time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Value:          decimal.MustParse("0"),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			Quality:        "A",
+			UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+		},
+		12: {
+			Nmi:            "NEM1201009",
+			NmiSuffix:      "E1",
+			IntervalStart:  time.Date(2005, time.March, 1, 6, 0, 0, 0, time.UTC),
+			Value:          decimal.MustParse("0.461"),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			Quality:        "A",
+			UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+		},
+		47: {
+			Nmi:            "NEM1201009",
+			NmiSuffix:      "E1",
+			IntervalStart:  time.Date(2005, time.March, 1, 23, 30, 0, 0, time.UTC),
+			Value:          decimal.MustParse("0.231"),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			Quality:        "A",
+			UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+		},
+	}
+	for i, intervalReading := range expected {
+		if reflect.DeepEqual(intervalReading, intervalReadings[i]) != true {
+			t.Errorf("Expected %+v, got %+v instead", intervalReading, intervalReadings[i])
+		}
+	}
+}
+
+type ProcessNmiBlockIntervalEventsTestCase struct {
+	Name             string
+	NmiBlockRecords  []string
+	IntervalReadings map[int]*model.IntervalReadings
+	Err              error
+}
+
+func TestProcessNmiBlockIntervalEvents(t *testing.T) {
+	dataStream := &model.DataStreams{
+		Nmi:            "NEM1201009",
+		NmiSuffix:      "E1",
+		Uom:            "kWh",
+		IntervalLength: 30,
+	}
+	reasonCode := int32(53)
+	reasonDescription := "Reading"
+	resetReasonCode := int32(79)
+	resetReasonDescription := "Reset"
+
+	tests := []ProcessNmiBlockIntervalEventsTestCase{
+		{
+			Name: "Happy Case - interval events cover every interval",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,20,A,,",
+				"400,21,48,S53,53,Reading",
+			},
+			IntervalReadings: map[int]*model.IntervalReadings{
+				0: {
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					IntervalStart:  time.Date(2005, time.March, 1, 0, 0, 0, 0, time.UTC),
+					Value:          decimal.MustParse("0.125"),
+					Uom:            "kWh",
+					OriginalUom:    "kWh",
+					Quality:        "A",
+					UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+				19: {
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					IntervalStart:  time.Date(2005, time.March, 1, 9, 30, 0, 0, time.UTC),
+					Value:          decimal.MustParse("0.125"),
+					Uom:            "kWh",
+					OriginalUom:    "kWh",
+					Quality:        "A",
+					UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+				20: {
+					Nmi:               "NEM1201009",
+					NmiSuffix:         "E1",
+					IntervalStart:     time.Date(2005, time.March, 1, 10, 0, 0, 0, time.UTC),
+					Value:             decimal.MustParse("0.125"),
+					Uom:               "kWh",
+					OriginalUom:       "kWh",
+					Quality:           "S53",
+					ReasonCode:        &reasonCode,
+					ReasonDescription: &reasonDescription,
+					UpdateDateTime:    time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+				47: {
+					Nmi:               "NEM1201009",
+					NmiSuffix:         "E1",
+					IntervalStart:     time.Date(2005, time.March, 1, 23, 30, 0, 0, time.UTC),
+					Value:             decimal.MustParse("0.125"),
+					Uom:               "kWh",
+					OriginalUom:       "kWh",
+					Quality:           "S53",
+					ReasonCode:        &reasonCode,
+					ReasonDescription: &reasonDescription,
+					UpdateDateTime:    time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+			},
+			Err: nil,
+		},
+		{
+			Name: "Error Case - interval events do not cover every interval",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,20,A,,",
+				buildIntervalRecord("20050302", "0.125", 48, "A"),
+			},
+			Err: errors.New("record 300: interval 21 of a meter reading with quality method V is not covered by an interval event"),
+		},
+		{
+			Name: "Error Case - no interval events for the last meter reading",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+			},
+			Err: errors.New("record 300: interval 1 of a meter reading with quality method V is not covered by an interval event"),
+		},
+		{
+			Name: "Happy Case - interval events of a meter reading without V quality method need not cover every interval",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "A"),
+				"400,1,10,A,79,Reset",
+			},
+			IntervalReadings: map[int]*model.IntervalReadings{
+				9: {
+					Nmi:               "NEM1201009",
+					NmiSuffix:         "E1",
+					IntervalStart:     time.Date(2005, time.March, 1, 4, 30, 0, 0, time.UTC),
+					Value:             decimal.MustParse("0.125"),
+					Uom:               "kWh",
+					OriginalUom:       "kWh",
+					Quality:           "A",
+					ReasonCode:        &resetReasonCode,
+					ReasonDescription: &resetReasonDescription,
+					UpdateDateTime:    time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+				10: {
+					Nmi:            "NEM1201009",
+					NmiSuffix:      "E1",
+					IntervalStart:  time.Date(2005, time.March, 1, 5, 0, 0, 0, time.UTC),
+					Value:          decimal.MustParse("0.125"),
+					Uom:            "kWh",
+					OriginalUom:    "kWh",
+					Quality:        "A",
+					UpdateDateTime: time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC),
+				},
+			},
+			Err: nil,
+		},
+		{
+			Name: "Error Case - interval event without a meter reading",
+			NmiBlockRecords: []string{
+				"400,1,48,A,,",
+			},
+			Err: errors.New("record 400: interval event record does not follow a meter reading"),
+		},
+		{
+			Name: "Error Case - interval events overlap",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,20,A,,",
+				"400,20,48,E52,,",
+			},
+			Err: errors.New("record 400: interval 20 is covered by more than one interval event"),
+		},
+		{
+			Name: "Error Case - interval event out of range",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,49,A,,",
+			},
+			Err: errors.New("record 400: interval event covers intervals 1 to 49, expected intervals within 1 to 48"),
+		},
+		{
+			Name: "Error Case - interval event with V quality method",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,48,V,,",
+			},
+			Err: errors.New("record 400 field 3: interval event has invalid quality method \"V\""),
+		},
+		{
+			Name: "Error Case - interval event incomplete",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,48",
+			},
+			Err: errors.New("record 400: interval event does not have enough values"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			results, err := nem12.ProcessNmiBlock(tt.NmiBlockRecords, dataStream)
+			intervalReadings := results.IntervalReadings
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			for i, intervalReading := range tt.IntervalReadings {
+				if reflect.DeepEqual(intervalReading, intervalReadings[i]) != true {
+					t.Errorf("Expected %+v, got %+v instead", intervalReading, intervalReadings[i])
+				}
+			}
+		})
+	}
+}
+
+type ProcessNmiBlockB2bDetailsTestCase struct {
+	Name            string
+	NmiBlockRecords []string
+	B2bDetails      []*model.B2bDetails
+	Err             error
+}
+
+func TestProcessNmiBlockB2bDetails(t *testing.T) {
+	dataStream := &model.DataStreams{
+		Nmi:            "NEM1201009",
+		NmiSuffix:      "E1",
+		Uom:            "kWh",
+		IntervalLength: 30,
+	}
+	readDateTime := time.Date(2005, time.March, 10, 12, 10, 4, 0, time.UTC)
+	indexRead := "001123.5"
+
+	tests := []ProcessNmiBlockB2bDetailsTestCase{
+		{
+			Name: "Happy Case - b2b details",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "A"),
+				"500,O,S01009,20050310121004,",
+				"500,S,S01010,20050310121004,001123.5",
+				"500,A,,,",
+			},
+			B2bDetails: []*model.B2bDetails{
+				{
+					Nmi:             "NEM1201009",
+					NmiSuffix:       "E1",
+					TransCode:       "O",
+					RetServiceOrder: "S01009",
+					ReadDateTime:    &readDateTime,
+				},
+				{
+					Nmi:             "NEM1201009",
+					NmiSuffix:       "E1",
+					TransCode:       "S",
+					RetServiceOrder: "S01010",
+					ReadDateTime:    &readDateTime,
+					IndexRead:       &indexRead,
+				},
+				{
+					Nmi:             "NEM1201009",
+					NmiSuffix:       "E1",
+					TransCode:       "A",
+					RetServiceOrder: "",
+				},
+			},
+			Err: nil,
+		},
+		{
+			Name: "Error Case - read date time wrong format",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "A"),
+				"500,O,S01009,2005031012,",
+			},
+			Err: errors.New("record 500 field 3: Failed to parse read date time: parsing time \"2005031012\" as \"20060102150405\": cannot parse \"\" as \"04\""),
+		},
+		{
+			Name: "Error Case - b2b details incomplete",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "A"),
+				"500,O,S01009",
+			},
+			Err: errors.New("record 500: b2b details does not have enough values"),
+		},
+		{
+			Name: "Error Case - interval event follows b2b details",
+			NmiBlockRecords: []string{
+				buildIntervalRecord("20050301", "0.125", 48, "V"),
+				"400,1,48,A,,",
+				"500,O,S01009,20050310121004,",
+				"400,1,48,A,,",
+			},
+			Err: errors.New("record 400: interval event record does not follow a meter reading"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			results, err := nem12.ProcessNmiBlock(tt.NmiBlockRecords, dataStream)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.B2bDetails, results.B2bDetails) != true {
+				t.Errorf("Expected %+v, got %+v instead", tt.B2bDetails, results.B2bDetails)
+			}
+		})
+	}
+}
+
+type ExactConsumptionTestCase struct {
+	Name           string
+	Uom            string
+	IntervalLength int32
+	Value          string
+	Precision      nem12.PrecisionRule
+	Consumption    decimal.Decimal
+	IntervalValue  decimal.Decimal
+}
+
+func TestProcessNmiBlockExactConsumption(t *testing.T) {
+	tests := []ExactConsumptionTestCase{
+		{
+			Name:           "Happy Case - values that a float64 cannot represent",
+			Uom:            "kWh",
+			IntervalLength: 30,
+			Value:          "0.1",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("4.8"),
+			IntervalValue:  decimal.MustParse("0.1"),
+		},
+		{
+			Name:           "Happy Case - 5 minute intervals",
+			Uom:            "kWh",
+			IntervalLength: 5,
+			Value:          "0.333",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("95.904"),
+			IntervalValue:  decimal.MustParse("0.333"),
+		},
+		{
+			Name:           "Happy Case - Wh values are converted exactly",
+			Uom:            "Wh",
+			IntervalLength: 30,
+			Value:          "123.4",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("5.9232"),
+			IntervalValue:  decimal.MustParse("0.1234"),
+		},
+		{
+			Name:           "Happy Case - Wh values keep their decimal places when converted",
+			Uom:            "Wh",
+			IntervalLength: 30,
+			Value:          "0.5",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("0.024"),
+			IntervalValue:  decimal.MustParse("0.0005"),
+		},
+		{
+			Name:           "Happy Case - values are rounded half up in the unit of the file",
+			Uom:            "Wh",
+			IntervalLength: 30,
+			Value:          "0.0005",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("0.000048"),
+			IntervalValue:  decimal.MustParse("0.000001"),
+		},
+		{
+			Name:           "Happy Case - configured precision",
+			Uom:            "Wh",
+			IntervalLength: 30,
+			Value:          "1234.45675",
+			Precision:      nem12.PrecisionRule{DecimalPlaces: 4, Rounding: decimal.RoundHalfEven},
+			Consumption:    decimal.MustParse("59.2539264"),
+			IntervalValue:  decimal.MustParse("1.2344568"),
+		},
+		{
+			Name:           "Happy Case - MWh values are rounded in kWh",
+			Uom:            "MWh",
+			IntervalLength: 30,
+			Value:          "0.0012345",
+			Precision:      nem12.DefaultPrecisionRule(),
+			Consumption:    decimal.MustParse("59.28"),
+			IntervalValue:  decimal.MustParse("1.235"),
+		},
+		{
+			Name:           "Happy Case - configured precision of MWh values",
+			Uom:            "MWh",
+			IntervalLength: 30,
+			Value:          "0.00123445",
+			Precision:      nem12.PrecisionRule{DecimalPlaces: 4, Rounding: decimal.RoundHalfEven},
+			Consumption:    decimal.MustParse("59.2512"),
+			IntervalValue:  decimal.MustParse("1.2344"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			dataStream := &model.DataStreams{
+				Nmi:            "NEM1201009",
+				NmiSuffix:      "E1",
+				Uom:            tt.Uom,
+				IntervalLength: tt.IntervalLength,
+			}
+			numIntervals := int(24 * 60 / tt.IntervalLength)
+			results, err := tt.Precision.ProcessNmiBlock([]string{buildIntervalRecord("20050301", tt.Value, numIntervals, "A")}, dataStream)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v instead", err)
+			}
+
+			// the consumption is the exact sum of the interval values
+			if results.MeterReadings[0].Consumption != tt.Consumption {
+				t.Errorf("Expected consumption %v, got %v instead", tt.Consumption, results.MeterReadings[0].Consumption)
+			}
+			sum := decimal.Zero
+			for _, intervalReading := range results.IntervalReadings {
+				if intervalReading.Value != tt.IntervalValue {
+					t.Fatalf("Expected interval value %v, got %v instead", tt.IntervalValue, intervalReading.Value)
+				}
+				sum, err = sum.Add(intervalReading.Value)
+				if err != nil {
+					t.Fatalf("Expected no error, got %v instead", err)
+				}
+			}
+			if sum != results.MeterReadings[0].Consumption {
+				t.Errorf("Expected consumption %v to equal the sum of its interval values %v", results.MeterReadings[0].Consumption, sum)
+			}
+		})
+	}
+}
+
+// buildIntervalRecord creates a NMI 300 record for the given date with numIntervals copies of value.
+func buildIntervalRecord(date string, value string, numIntervals int, qualityMethod string) string {
+	values := make([]string, numIntervals)
+	for i := range values {
+		values[i] = value
+	}
+	return "300," + date + "," + strings.Join(values, ",") + "," + qualityMethod + ",,,20050310121004,20050310182204"
+}
@@ -0,0 +1,153 @@
+package nem12
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+)
+
+// ProcessNem13Block is the NmiBlockProcessor for NEM13 files, which processes a NMI 250 record and its NMI 550 record
+// with the DefaultPrecisionRule.
+func ProcessNem13Block(accumulationRecord string, nmiBlockRecords []string) (results NmiResultsParams, err error) {
+	return DefaultPrecisionRule().ProcessNem13Block(accumulationRecord, nmiBlockRecords)
+}
+
+// ProcessNem13Block is the NmiBlockProcessor for NEM13 files that converts register reads with the PrecisionRule p.
+func (p PrecisionRule) ProcessNem13Block(accumulationRecord string, nmiBlockRecords []string) (results NmiResultsParams, err error) {
+	results = NmiResultsParams{
+		AccumulationReadings: []*model.AccumulationReadings{},
+	}
+
+	accumulationReading, err := p.ParseAccumulationRecord(accumulationRecord)
+	if err != nil {
+		return results, err
+	}
+
+	for i, nmiBlockRecord := range nmiBlockRecords {
+		splitLine := strings.Split(nmiBlockRecord, ",")
+		// the 250 record is record 0 of the block
+		if splitLine[0] != RecordIndicator_550 {
+			err = newValidationError(splitLine[0], WholeRecord, errors.New("not allowed in a nmi block"))
+			return results, locateRecord(err, i+1)
+		}
+		if i > 0 {
+			err = newValidationError(RecordIndicator_550, WholeRecord, errors.New("accumulation reading has more than one b2b details record"))
+			return results, locateRecord(err, i+1)
+		}
+		err = applyAccumulationB2bDetailsRecord(splitLine, accumulationReading)
+		if err != nil {
+			return results, locateRecord(err, i+1)
+		}
+	}
+
+	results.AccumulationReadings = append(results.AccumulationReadings, accumulationReading)
+	return results, nil
+}
+
+// ParseAccumulationRecord creates an AccumulationReadings model object from a NMI 250 record.
+// The register reads and quantity are converted from the UOM field into its canonical unit with the DefaultPrecisionRule.
+func ParseAccumulationRecord(accumulationRecord string) (accumulationReading *model.AccumulationReadings, err error) {
+	return DefaultPrecisionRule().ParseAccumulationRecord(accumulationRecord)
+}
+
+// ParseAccumulationRecord creates an AccumulationReadings model object from a NMI 250 record in the same way as the
+// ParseAccumulationRecord function, with the register reads and quantity converted with the PrecisionRule p.
+func (p PrecisionRule) ParseAccumulationRecord(accumulationRecord string) (accumulationReading *model.AccumulationReadings, err error) {
+	splitLine := strings.Split(accumulationRecord, ",")
+	if len(splitLine) < 23 {
+		return nil, newValidationError(RecordIndicator_250, WholeRecord, errors.New("accumulation reading does not have enough values"))
+	}
+	if len(splitLine) > 23 {
+		return nil, newValidationError(RecordIndicator_250, WholeRecord, errors.New("accumulation reading has too many values"))
+	}
+
+	nmi, err := ParseNmi(splitLine[1])
+	if err != nil {
+		return nil, newValidationError(RecordIndicator_250, 1, err)
+	}
+	unit, err := ParseUnitOfMeasure(splitLine[19])
+	if err != nil {
+		return nil, newValidationError(RecordIndicator_250, 19, err)
+	}
+	previousRegisterRead, err := parseConsumptionValue(splitLine[8], unit, p)
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 8, fmt.Errorf("%s: %w", "Failed to parse previous register read", err))
+	}
+	previousRegisterReadDateTime, err := time.Parse(RecordDateTimeLayout, splitLine[9])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 9, fmt.Errorf("%s: %w", "Failed to parse previous register read date time", err))
+	}
+	previousReasonCode, err := parseReasonCode(splitLine[11])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 11, err)
+	}
+	currentRegisterRead, err := parseConsumptionValue(splitLine[13], unit, p)
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 13, fmt.Errorf("%s: %w", "Failed to parse current register read", err))
+	}
+	currentRegisterReadDateTime, err := time.Parse(RecordDateTimeLayout, splitLine[14])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 14, fmt.Errorf("%s: %w", "Failed to parse current register read date time", err))
+	}
+	currentReasonCode, err := parseReasonCode(splitLine[16])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 16, err)
+	}
+	quantity, err := parseConsumptionValue(splitLine[18], unit, p)
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 18, fmt.Errorf("%s: %w", "Failed to parse quantity", err))
+	}
+	nextScheduledReadDate, err := parseOptionalDate(splitLine[20])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 20, fmt.Errorf("%s: %w", "Failed to parse next scheduled read date", err))
+	}
+	updateDateTime, err := time.Parse(RecordDateTimeLayout, splitLine[21])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 21, fmt.Errorf("%s: %w", "Failed to parse update date time", err))
+	}
+	msatsLoadDateTime, err := parseOptionalDateTime(splitLine[22])
+	if err != nil {
+		return nil, newParseError(RecordIndicator_250, 22, fmt.Errorf("%s: %w", "Failed to parse MSATS load date time", err))
+	}
+
+	return &model.AccumulationReadings{
+		Nmi:                          nmi,
+		NmiConfiguration:             splitLine[2],
+		RegisterID:                   splitLine[3],
+		NmiSuffix:                    splitLine[4],
+		MdmDataStreamIdentifier:      optionalString(splitLine[5]),
+		MeterSerialNumber:            splitLine[6],
+		DirectionIndicator:           splitLine[7],
+		PreviousRegisterRead:         previousRegisterRead,
+		PreviousRegisterReadDateTime: previousRegisterReadDateTime,
+		PreviousQualityMethod:        splitLine[10],
+		PreviousReasonCode:           previousReasonCode,
+		PreviousReasonDescription:    optionalString(splitLine[12]),
+		CurrentRegisterRead:          currentRegisterRead,
+		CurrentRegisterReadDateTime:  currentRegisterReadDateTime,
+		CurrentQualityMethod:         splitLine[15],
+		CurrentReasonCode:            currentReasonCode,
+		CurrentReasonDescription:     optionalString(splitLine[17]),
+		Quantity:                     quantity,
+		Uom:                          unit.Canonical,
+		OriginalUom:                  unit.Original,
+		NextScheduledReadDate:        nextScheduledReadDate,
+		UpdateDateTime:               updateDateTime,
+		MsatsLoadDateTime:            msatsLoadDateTime,
+	}, nil
+}
+
+// applyAccumulationB2bDetailsRecord sets the transaction codes and service orders of a NMI 550 record on the accumulation reading it follows.
+func applyAccumulationB2bDetailsRecord(splitLine []string, accumulationReading *model.AccumulationReadings) error {
+	if len(splitLine) < 5 {
+		return newValidationError(RecordIndicator_550, WholeRecord, errors.New("b2b details does not have enough values"))
+	}
+	accumulationReading.PreviousTransCode = optionalString(splitLine[1])
+	accumulationReading.PreviousRetServiceOrder = optionalString(splitLine[2])
+	accumulationReading.CurrentTransCode = optionalString(splitLine[3])
+	accumulationReading.CurrentRetServiceOrder = optionalString(splitLine[4])
+	return nil
+}
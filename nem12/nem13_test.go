@@ -0,0 +1,92 @@
+package nem12_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ts33/energy-reading/nem12"
+)
+
+type ProcessNem13BlockTestCase struct {
+	Name               string
+	AccumulationRecord string
+	NmiBlockRecords    []string
+	Err                error
+}
+
+func TestProcessNem13Block(t *testing.T) {
+	accumulationRecord := "250,NEM1301001,11,01,11,11,METSER123,E,000020,20031001103230,A,,,000010,20040201100030,E64,77,,343.5,kWh,20040509,20040202125010,20040203000130"
+
+	tests := []ProcessNem13BlockTestCase{
+		{
+			Name:               "Happy Case - accumulation reading without b2b details",
+			AccumulationRecord: accumulationRecord,
+			NmiBlockRecords:    []string{},
+			Err:                nil,
+		},
+		{
+			Name:               "Error Case - more than one b2b details record",
+			AccumulationRecord: accumulationRecord,
+			NmiBlockRecords:    []string{"550,N,,A,", "550,N,,A,"},
+			Err:                errors.New("record 550: accumulation reading has more than one b2b details record"),
+		},
+		{
+			Name:               "Error Case - NEM12 record in NEM13 block",
+			AccumulationRecord: accumulationRecord,
+			NmiBlockRecords:    []string{"500,O,S01009,20050310121004,"},
+			Err:                errors.New("record 500: not allowed in a nmi block"),
+		},
+		{
+			Name:               "Error Case - b2b details incomplete",
+			AccumulationRecord: accumulationRecord,
+			NmiBlockRecords:    []string{"550,N,"},
+			Err:                errors.New("record 550: b2b details does not have enough values"),
+		},
+		{
+			Name:               "Error Case - accumulation reading incomplete",
+			AccumulationRecord: "250,NEM1301001,11,01,11,11,METSER123,E,000020,20031001103230,A,,,000010",
+			NmiBlockRecords:    []string{},
+			Err:                errors.New("record 250: accumulation reading does not have enough values"),
+		},
+		{
+			Name:               "Error Case - current register read date time wrong format",
+			AccumulationRecord: "250,NEM1301001,11,01,11,11,METSER123,E,000020,20031001103230,A,,,000010,200402011000,E64,77,,343.5,kWh,20040509,20040202125010,20040203000130",
+			NmiBlockRecords:    []string{},
+			Err:                errors.New("record 250 field 14: Failed to parse current register read date time: parsing time \"200402011000\" as \"20060102150405\": cannot parse \"\" as \"05\""),
+		},
+		{
+			Name:               "Error Case - unit of measure not supported",
+			AccumulationRecord: "250,NEM1301001,11,01,11,11,METSER123,E,000020,20031001103230,A,,,000010,20040201100030,E64,77,,343.5,gal,20040509,20040202125010,20040203000130",
+			NmiBlockRecords:    []string{},
+			Err:                errors.New("record 250 field 19: unit of measure gal is not supported"),
+		},
+		{
+			Name:               "Error Case - quantity not a number",
+			AccumulationRecord: "250,NEM1301001,11,01,11,11,METSER123,E,000020,20031001103230,A,,,000010,20040201100030,E64,77,,abc,kWh,20040509,20040202125010,20040203000130",
+			NmiBlockRecords:    []string{},
+			Err:                errors.New("record 250 field 18: Failed to parse quantity: decimal.Parse: parsing \"abc\": invalid syntax"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			results, err := nem12.ProcessNem13Block(tt.AccumulationRecord, tt.NmiBlockRecords)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if len(results.AccumulationReadings) != 1 {
+				t.Errorf("Expected 1 accumulation reading, got %v instead", len(results.AccumulationReadings))
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package nem12
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	NmiLength             = 10
+	NmiWithChecksumLength = NmiLength + 1
+)
+
+// ParseNmi validates the structure of a NMI and returns it without its checksum digit.
+// A NMI has 10 alphanumeric characters, and cannot contain the letters I or O.
+// An 11th character is treated as the AEMO NMI checksum digit, which must match NmiChecksum.
+func ParseNmi(nmi string) (string, error) {
+	if len(nmi) != NmiLength && len(nmi) != NmiWithChecksumLength {
+		return "", fmt.Errorf("nmi %s does not have %d characters", nmi, NmiLength)
+	}
+	for _, c := range nmi[:NmiLength] {
+		if c == 'I' || c == 'O' {
+			return "", fmt.Errorf("nmi %s contains the letter %c", nmi, c)
+		}
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return "", fmt.Errorf("nmi %s contains the character %q which is not alphanumeric", nmi, c)
+		}
+	}
+	if len(nmi) == NmiWithChecksumLength {
+		checksum := strconv.Itoa(NmiChecksum(nmi[:NmiLength]))
+		if nmi[NmiLength:] != checksum {
+			return "", fmt.Errorf("nmi %s has checksum %s, expected %s", nmi, nmi[NmiLength:], checksum)
+		}
+	}
+	return nmi[:NmiLength], nil
+}
+
+// NmiChecksum calculates the AEMO NMI checksum digit of a 10 character NMI.
+// Starting from the right most character, the ASCII value of every second character is doubled,
+// and the digits of all the values are summed. The checksum is the amount needed to round the sum up to the next multiple of 10.
+func NmiChecksum(nmi string) int {
+	sum := 0
+	double := true
+	for i := len(nmi) - 1; i >= 0; i-- {
+		value := int(nmi[i])
+		if double {
+			value *= 2
+		}
+		double = !double
+		for value > 0 {
+			sum += value % 10
+			value /= 10
+		}
+	}
+	return (10 - sum%10) % 10
+}
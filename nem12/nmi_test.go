@@ -0,0 +1,105 @@
+package nem12_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ts33/energy-reading/nem12"
+)
+
+type ParseNmiTestCase struct {
+	Name string
+	Nmi  string
+	Want string
+	Err  error
+}
+
+func TestParseNmi(t *testing.T) {
+	tests := []ParseNmiTestCase{
+		{
+			Name: "Happy Case - nmi without checksum",
+			Nmi:  "NEM1201009",
+			Want: "NEM1201009",
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - numeric nmi with checksum",
+			Nmi:  "20019857328",
+			Want: "2001985732",
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - alphanumeric nmi with checksum",
+			Nmi:  "QAAAVZZZZZ3",
+			Want: "QAAAVZZZZZ",
+			Err:  nil,
+		},
+		{
+			Name: "Error Case - nmi too short",
+			Nmi:  "NEM100",
+			Err:  errors.New("nmi NEM100 does not have 10 characters"),
+		},
+		{
+			Name: "Error Case - nmi too long",
+			Nmi:  "NEM120100912",
+			Err:  errors.New("nmi NEM120100912 does not have 10 characters"),
+		},
+		{
+			Name: "Error Case - nmi contains the letter O",
+			Nmi:  "NEM12O1009",
+			Err:  errors.New("nmi NEM12O1009 contains the letter O"),
+		},
+		{
+			Name: "Error Case - nmi contains the letter I",
+			Nmi:  "NEMI201009",
+			Err:  errors.New("nmi NEMI201009 contains the letter I"),
+		},
+		{
+			Name: "Error Case - nmi contains a character that is not alphanumeric",
+			Nmi:  "NEM-201009",
+			Err:  errors.New("nmi NEM-201009 contains the character '-' which is not alphanumeric"),
+		},
+		{
+			Name: "Error Case - checksum does not match",
+			Nmi:  "20019857321",
+			Err:  errors.New("nmi 20019857321 has checksum 1, expected 8"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			nmi, err := nem12.ParseNmi(tt.Nmi)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if nmi != tt.Want {
+				t.Errorf("Expected nmi %v, got %v instead", tt.Want, nmi)
+			}
+		})
+	}
+}
+
+func TestNmiChecksum(t *testing.T) {
+	// examples from the AEMO NMI allocation list
+	expected := map[string]int{
+		"2001985732": 8,
+		"2001985733": 6,
+		"QAAAVZZZZZ": 3,
+		"VAAA000065": 7,
+	}
+	for nmi, checksum := range expected {
+		if result := nem12.NmiChecksum(nmi); result != checksum {
+			t.Errorf("Expected checksum %v for nmi %v, got %v instead", checksum, nmi, result)
+		}
+	}
+}
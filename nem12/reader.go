@@ -0,0 +1,102 @@
+package nem12
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	RecordIndicatorLength = 3
+	// MaxRecordLength is the longest line the RecordReader accepts, which comfortably fits a 5 minute NMI 300 record.
+	MaxRecordLength = 1024 * 1024
+)
+
+// utf8ByteOrderMark is written by some editors at the start of a file, before the 100 record.
+const utf8ByteOrderMark = "\ufeff"
+
+// RecordReader reads the records of an NMI file one line at a time.
+// It strips a UTF-8 byte order mark, CRLF line endings and whitespace around every field, and skips blank lines,
+// so that every record returned starts with a record indicator.
+type RecordReader struct {
+	scanner    *bufio.Scanner
+	lineNumber int
+	record     string
+	indicator  string
+	err        error
+}
+
+// NewRecordReader creates a RecordReader that reads records from r.
+func NewRecordReader(r io.Reader) *RecordReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxRecordLength)
+	return &RecordReader{scanner: scanner}
+}
+
+// Next advances the RecordReader to the next record, which is then available through Record and Indicator.
+// It returns false when there are no records left, or when a line cannot be read, in which case Err returns the reason.
+func (r *RecordReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for r.scanner.Scan() {
+		r.lineNumber++
+		line := r.scanner.Text()
+		if r.lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8ByteOrderMark)
+		}
+		line = normaliseRecord(line)
+		if line == "" {
+			continue
+		}
+
+		indicator, _, _ := strings.Cut(line, ",")
+		if len(indicator) != RecordIndicatorLength {
+			location := RecordLocation{Line: r.lineNumber, Field: WholeRecord}
+			r.err = &ParseError{location, fmt.Errorf("%q does not start with a record indicator", line)}
+			return false
+		}
+		r.record = line
+		r.indicator = indicator
+		return true
+	}
+	if err := r.scanner.Err(); err != nil {
+		r.err = &ParseError{RecordLocation{Line: r.lineNumber + 1, Field: WholeRecord}, err}
+	}
+	return false
+}
+
+// Record returns the record that was read by the last call to Next.
+func (r *RecordReader) Record() string {
+	return r.record
+}
+
+// Indicator returns the record indicator of the record that was read by the last call to Next.
+func (r *RecordReader) Indicator() string {
+	return r.indicator
+}
+
+// LineNumber returns the line number of the record that was read by the last call to Next, starting from 1.
+func (r *RecordReader) LineNumber() int {
+	return r.lineNumber
+}
+
+// Err returns the ParseError that stopped the RecordReader, if any.
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// normaliseRecord trims the whitespace around a line and around each of its fields.
+// The trailing \r of a CRLF line ending is removed as whitespace.
+func normaliseRecord(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.ContainsAny(line, " \t\r") {
+		return line
+	}
+	fields := strings.Split(line, ",")
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return strings.Join(fields, ",")
+}
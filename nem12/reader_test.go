@@ -0,0 +1,103 @@
+package nem12_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ts33/energy-reading/nem12"
+)
+
+type RecordReaderTestCase struct {
+	Name        string
+	Input       string
+	Records     []string
+	LineNumbers []int
+	Err         error
+}
+
+func TestRecordReader(t *testing.T) {
+	tests := []RecordReaderTestCase{
+		{
+			Name:        "Happy Case - LF line endings",
+			Input:       "100,NEM12,200506081149,UNITEDDP,NEMMCO\n200,NEM1201009\n900",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO", "200,NEM1201009", "900"},
+			LineNumbers: []int{1, 2, 3},
+			Err:         nil,
+		},
+		{
+			Name:        "Happy Case - CRLF line endings",
+			Input:       "100,NEM12,200506081149,UNITEDDP,NEMMCO\r\n200,NEM1201009,\r\n900\r\n",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO", "200,NEM1201009,", "900"},
+			LineNumbers: []int{1, 2, 3},
+			Err:         nil,
+		},
+		{
+			Name:        "Happy Case - byte order mark",
+			Input:       "\ufeff100,NEM12,200506081149,UNITEDDP,NEMMCO\n900",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO", "900"},
+			LineNumbers: []int{1, 2},
+			Err:         nil,
+		},
+		{
+			Name:        "Happy Case - blank lines and trailing newlines after 900",
+			Input:       "100,NEM12,200506081149,UNITEDDP,NEMMCO\n\n  \n200,NEM1201009\n900\n\n\n",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO", "200,NEM1201009", "900"},
+			LineNumbers: []int{1, 4, 5},
+			Err:         nil,
+		},
+		{
+			Name:        "Happy Case - whitespace around fields",
+			Input:       "  100, NEM12 ,200506081149,\tUNITEDDP,NEMMCO  \n900",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO", "900"},
+			LineNumbers: []int{1, 2},
+			Err:         nil,
+		},
+		{
+			Name:        "Error Case - line shorter than a record indicator",
+			Input:       "100,NEM12,200506081149,UNITEDDP,NEMMCO\n30\n900",
+			Records:     []string{"100,NEM12,200506081149,UNITEDDP,NEMMCO"},
+			LineNumbers: []int{1},
+			Err:         errors.New("line 2: \"30\" does not start with a record indicator"),
+		},
+		{
+			Name:        "Error Case - record indicator too long",
+			Input:       "1000,NEM12,200506081149,UNITEDDP,NEMMCO",
+			Records:     []string{},
+			LineNumbers: []int{},
+			Err:         errors.New("line 1: \"1000,NEM12,200506081149,UNITEDDP,NEMMCO\" does not start with a record indicator"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			reader := nem12.NewRecordReader(strings.NewReader(tt.Input))
+			records := []string{}
+			lineNumbers := []int{}
+			for reader.Next() {
+				records = append(records, reader.Record())
+				lineNumbers = append(lineNumbers, reader.LineNumber())
+			}
+
+			// assert that errors are raised
+			err := reader.Err()
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+			} else if tt.Err != nil {
+				t.Errorf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.Records, records) != true {
+				t.Errorf("Expected records %q, got %q instead", tt.Records, records)
+			}
+			if reflect.DeepEqual(tt.LineNumbers, lineNumbers) != true {
+				t.Errorf("Expected line numbers %v, got %v instead", tt.LineNumbers, lineNumbers)
+			}
+		})
+	}
+}
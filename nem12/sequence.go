@@ -0,0 +1,80 @@
+package nem12
+
+import (
+	"fmt"
+	"strings"
+)
+
+// recordIndicator_Start is the state of a RecordSequence before any record has been read.
+const recordIndicator_Start = ""
+
+// Nem12RecordTransitions contains the records that are allowed to follow each record of a NEM12 file,
+// following the MDFF nesting rules of 100 → (200 → (300 → 400*)* → 500*)* → 900.
+var Nem12RecordTransitions = map[string][]string{
+	recordIndicator_Start: {RecordIndicator_100},
+	RecordIndicator_100:   {RecordIndicator_200, RecordIndicator_900},
+	RecordIndicator_200:   {RecordIndicator_300, RecordIndicator_500, RecordIndicator_200, RecordIndicator_900},
+	RecordIndicator_300:   {RecordIndicator_300, RecordIndicator_400, RecordIndicator_500, RecordIndicator_200, RecordIndicator_900},
+	RecordIndicator_400:   {RecordIndicator_400, RecordIndicator_300, RecordIndicator_500, RecordIndicator_200, RecordIndicator_900},
+	RecordIndicator_500:   {RecordIndicator_500, RecordIndicator_200, RecordIndicator_900},
+	RecordIndicator_900:   {},
+}
+
+// Nem13RecordTransitions contains the records that are allowed to follow each record of a NEM13 file,
+// following the MDFF nesting rules of 100 → (250 → 550?)* → 900.
+var Nem13RecordTransitions = map[string][]string{
+	recordIndicator_Start: {RecordIndicator_100},
+	RecordIndicator_100:   {RecordIndicator_250, RecordIndicator_900},
+	RecordIndicator_250:   {RecordIndicator_250, RecordIndicator_550, RecordIndicator_900},
+	RecordIndicator_550:   {RecordIndicator_250, RecordIndicator_900},
+	RecordIndicator_900:   {},
+}
+
+// RecordSequence is a state machine that validates the order of the records of an NMI file.
+type RecordSequence struct {
+	transitions map[string][]string
+	previous    string
+}
+
+// NewRecordSequence creates a RecordSequence for the records of a file with the given VersionHeader,
+// which starts before the 100 record.
+func NewRecordSequence(versionHeader string) *RecordSequence {
+	transitions := Nem12RecordTransitions
+	if versionHeader == VersionHeader_NEM13 {
+		transitions = Nem13RecordTransitions
+	}
+	return &RecordSequence{transitions: transitions, previous: recordIndicator_Start}
+}
+
+// Next moves the RecordSequence to the record with the given indicator that was read on lineNumber.
+// A ValidationError is returned if the record is not allowed to follow the previous record.
+func (s *RecordSequence) Next(indicator string, lineNumber int) error {
+	expected := s.transitions[s.previous]
+	for _, allowed := range expected {
+		if indicator == allowed {
+			s.previous = indicator
+			return nil
+		}
+	}
+
+	location := RecordLocation{Line: lineNumber, RecordIndicator: indicator, Field: WholeRecord}
+	if s.previous == recordIndicator_Start {
+		return &ValidationError{location, fmt.Errorf("not expected at the start of the file, expected one of %s", strings.Join(expected, ", "))}
+	}
+	if len(expected) == 0 {
+		return &ValidationError{location, fmt.Errorf("not expected after record %s, expected the end of the file", s.previous)}
+	}
+	return &ValidationError{location, fmt.Errorf("not expected after record %s, expected one of %s", s.previous, strings.Join(expected, ", "))}
+}
+
+// End validates that the RecordSequence finished on a 900 record when the file ended after lineNumber.
+func (s *RecordSequence) End(lineNumber int) error {
+	if s.previous == RecordIndicator_900 {
+		return nil
+	}
+	if s.previous == recordIndicator_Start {
+		return &ValidationError{RecordLocation{Line: lineNumber, Field: WholeRecord}, fmt.Errorf("file ended before record %s", RecordIndicator_100)}
+	}
+	location := RecordLocation{Line: lineNumber, RecordIndicator: s.previous, Field: WholeRecord}
+	return &ValidationError{location, fmt.Errorf("file ended after this record, expected one of %s", strings.Join(s.transitions[s.previous], ", "))}
+}
@@ -0,0 +1,119 @@
+package nem12_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ts33/energy-reading/nem12"
+)
+
+type RecordSequenceTestCase struct {
+	Name          string
+	VersionHeader string
+	Indicators    []string
+	Err           error
+}
+
+func TestRecordSequence(t *testing.T) {
+	tests := []RecordSequenceTestCase{
+		{
+			Name:          "Happy Case - NEM12 nesting",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "300", "400", "400", "300", "500", "500", "200", "300", "200", "900"},
+			Err:           nil,
+		},
+		{
+			Name:          "Happy Case - NEM12 data details without interval data",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "500", "200", "900"},
+			Err:           nil,
+		},
+		{
+			Name:          "Happy Case - NEM13 nesting",
+			VersionHeader: "NEM13",
+			Indicators:    []string{"100", "250", "550", "250", "250", "900"},
+			Err:           nil,
+		},
+		{
+			Name:          "Error Case - file does not start with 100",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"200"},
+			Err:           errors.New("line 1: record 200: not expected at the start of the file, expected one of 100"),
+		},
+		{
+			Name:          "Error Case - second 100 record",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "100"},
+			Err:           errors.New("line 3: record 100: not expected after record 200, expected one of 300, 500, 200, 900"),
+		},
+		{
+			Name:          "Error Case - 400 after 500",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "300", "500", "400"},
+			Err:           errors.New("line 5: record 400: not expected after record 500, expected one of 500, 200, 900"),
+		},
+		{
+			Name:          "Error Case - 300 after 500",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "300", "500", "300"},
+			Err:           errors.New("line 5: record 300: not expected after record 500, expected one of 500, 200, 900"),
+		},
+		{
+			Name:          "Error Case - record after 900",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "900", "200"},
+			Err:           errors.New("line 3: record 200: not expected after record 900, expected the end of the file"),
+		},
+		{
+			Name:          "Error Case - NEM12 record in NEM13 file",
+			VersionHeader: "NEM13",
+			Indicators:    []string{"100", "250", "300"},
+			Err:           errors.New("line 3: record 300: not expected after record 250, expected one of 250, 550, 900"),
+		},
+		{
+			Name:          "Error Case - two 550 records",
+			VersionHeader: "NEM13",
+			Indicators:    []string{"100", "250", "550", "550"},
+			Err:           errors.New("line 4: record 550: not expected after record 550, expected one of 250, 900"),
+		},
+		{
+			Name:          "Error Case - file ends without 900",
+			VersionHeader: "NEM12",
+			Indicators:    []string{"100", "200", "300"},
+			Err:           errors.New("line 3: record 300: file ended after this record, expected one of 300, 400, 500, 200, 900"),
+		},
+		{
+			Name:          "Error Case - empty file",
+			VersionHeader: "NEM12",
+			Indicators:    []string{},
+			Err:           errors.New("file ended before record 100"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			sequence := nem12.NewRecordSequence(tt.VersionHeader)
+			var err error
+			for i, indicator := range tt.Indicators {
+				err = sequence.Next(indicator, i+1)
+				if err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = sequence.End(len(tt.Indicators))
+			}
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+			} else if tt.Err != nil {
+				t.Errorf("Expected err %v, got no error instead", tt.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package nem12
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnitOfMeasure describes the canonical unit that a UOM field is normalised to,
+// and the Exponent of the power of ten that converts a value in the UOM field into the canonical unit.
+type UnitOfMeasure struct {
+	Original  string
+	Canonical string
+	Exponent  int32
+}
+
+// canonicalUnits maps the lower cased energy UOM values allowed by the MDFF specification to their canonical unit and exponent.
+// Active energy is normalised to kWh, and reactive and apparent energy are normalised to their kilo unit.
+// Only energy is supported, as the values of a UOM field are summed into the consumption of a meter reading.
+var canonicalUnits = map[string]UnitOfMeasure{
+	"wh":    {Canonical: "kWh", Exponent: -3},
+	"kwh":   {Canonical: "kWh", Exponent: 0},
+	"mwh":   {Canonical: "kWh", Exponent: 3},
+	"varh":  {Canonical: "kvarh", Exponent: -3},
+	"kvarh": {Canonical: "kvarh", Exponent: 0},
+	"mvarh": {Canonical: "kvarh", Exponent: 3},
+	"vah":   {Canonical: "kVAh", Exponent: -3},
+	"kvah":  {Canonical: "kVAh", Exponent: 0},
+	"mvah":  {Canonical: "kVAh", Exponent: 3},
+}
+
+// nonEnergyUnits holds the lower cased UOM values allowed by the MDFF specification that are not energy,
+// such as demand, voltage, current and power factor, whose values cannot be summed into a consumption.
+var nonEnergyUnits = map[string]bool{
+	"w": true, "kw": true, "mw": true,
+	"var": true, "kvar": true, "mvar": true,
+	"va": true, "kva": true, "mva": true,
+	"v": true, "kv": true,
+	"a": true, "ka": true,
+	"pf": true,
+}
+
+// ParseUnitOfMeasure looks up the canonical unit of a UOM field. The UOM field is not case sensitive.
+// An error is returned for a UOM that is not known, or that is not energy, so that values of such a unit are never stored.
+func ParseUnitOfMeasure(uom string) (unit UnitOfMeasure, err error) {
+	if nonEnergyUnits[strings.ToLower(uom)] {
+		return unit, fmt.Errorf("unit of measure %s is not an energy unit", uom)
+	}
+	unit, ok := canonicalUnits[strings.ToLower(uom)]
+	if !ok {
+		return unit, fmt.Errorf("unit of measure %s is not supported", uom)
+	}
+	unit.Original = uom
+	return unit, nil
+}
@@ -0,0 +1,93 @@
+package nem12_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ts33/energy-reading/nem12"
+)
+
+type ParseUnitOfMeasureTestCase struct {
+	Name string
+	Uom  string
+	Unit nem12.UnitOfMeasure
+	Err  error
+}
+
+func TestParseUnitOfMeasure(t *testing.T) {
+	tests := []ParseUnitOfMeasureTestCase{
+		{
+			Name: "Happy Case - kWh",
+			Uom:  "kWh",
+			Unit: nem12.UnitOfMeasure{Original: "kWh", Canonical: "kWh", Exponent: 0},
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - Wh",
+			Uom:  "Wh",
+			Unit: nem12.UnitOfMeasure{Original: "Wh", Canonical: "kWh", Exponent: -3},
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - MWh upper case",
+			Uom:  "MWH",
+			Unit: nem12.UnitOfMeasure{Original: "MWH", Canonical: "kWh", Exponent: 3},
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - kvarh",
+			Uom:  "kVArh",
+			Unit: nem12.UnitOfMeasure{Original: "kVArh", Canonical: "kvarh", Exponent: 0},
+			Err:  nil,
+		},
+		{
+			Name: "Happy Case - VAh",
+			Uom:  "VAh",
+			Unit: nem12.UnitOfMeasure{Original: "VAh", Canonical: "kVAh", Exponent: -3},
+			Err:  nil,
+		},
+		{
+			Name: "Error Case - demand unit",
+			Uom:  "kW",
+			Err:  errors.New("unit of measure kW is not an energy unit"),
+		},
+		{
+			Name: "Error Case - power factor",
+			Uom:  "pf",
+			Err:  errors.New("unit of measure pf is not an energy unit"),
+		},
+		{
+			Name: "Error Case - unknown unit",
+			Uom:  "therm",
+			Err:  errors.New("unit of measure therm is not supported"),
+		},
+		{
+			Name: "Error Case - empty unit",
+			Uom:  "",
+			Err:  errors.New("unit of measure  is not supported"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			unit, err := nem12.ParseUnitOfMeasure(tt.Uom)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				return
+			} else if tt.Err != nil {
+				t.Fatalf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.Unit, unit) != true {
+				t.Errorf("Expected %+v, got %+v instead", tt.Unit, unit)
+			}
+		})
+	}
+}
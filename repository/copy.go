@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	sql "database/sql"
+	"fmt"
+
+	postgres "github.com/go-jet/jet/v2/postgres"
+	"github.com/lib/pq"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+)
+
+// meterReadingsStaging is the temporary table that CopyMeterReadings streams readings into.
+// Temporary tables belong to the pg_temp schema of the session, and the staging table is aliased as meter_readings,
+// so that its columns are the columns of table.MeterReadings.
+var meterReadingsStaging = table.MeterReadings.FromSchema("pg_temp").WithSuffix("_staging")
+
+// CopyMeterReadings loads a list of MeterReadings for backfills, where a chunked bulk insert is too slow.
+// The readings are streamed into a temporary staging table with the COPY protocol, and then merged into meter_readings
+// with a single INSERT ... SELECT, all within one transaction that is started with opts.
+// The readings are merged in the same way as BulkUpsertMeterReadings, where a reading only replaces the stored reading
+// of its NMI, NMISuffix and timestamp when its UpdateDateTime is later.
+func CopyMeterReadings(ctx context.Context, db *sql.DB, readings []*model.MeterReadings, opts *sql.TxOptions) (UpsertResult, error) {
+	if len(readings) == 0 {
+		return UpsertResult{}, nil
+	}
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	// the rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// 1. create the staging table, which is dropped along with the transaction
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TEMPORARY TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+		meterReadingsStaging.TableName(), table.MeterReadings.TableName(),
+	))
+	if err != nil {
+		return UpsertResult{}, fmt.Errorf("%s: %w", "Failed to create staging table", err)
+	}
+
+	// 2. stream the readings into the staging table
+	columns := make([]string, 0, len(table.MeterReadings.MutableColumns))
+	for _, column := range table.MeterReadings.MutableColumns {
+		columns = append(columns, column.Name())
+	}
+	copyStmt, err := tx.PrepareContext(ctx, pq.CopyIn(meterReadingsStaging.TableName(), columns...))
+	if err != nil {
+		return UpsertResult{}, fmt.Errorf("%s: %w", "Failed to start copy", err)
+	}
+	for _, reading := range readings {
+		_, err = copyStmt.ExecContext(ctx, meterReadingValues(reading)...)
+		if err != nil {
+			copyStmt.Close()
+			return UpsertResult{}, fmt.Errorf("%s %s: %w", "Failed to copy meter reading for NMI", reading.Nmi, err)
+		}
+	}
+	// an Exec without values flushes the rows that are buffered by lib/pq
+	_, err = copyStmt.ExecContext(ctx)
+	if err != nil {
+		copyStmt.Close()
+		return UpsertResult{}, fmt.Errorf("%s: %w", "Failed to copy meter readings", err)
+	}
+	err = copyStmt.Close()
+	if err != nil {
+		return UpsertResult{}, fmt.Errorf("%s: %w", "Failed to copy meter readings", err)
+	}
+
+	// 3. merge the latest reading of every natural key in the staging table into meter_readings
+	latestReadings := postgres.SELECT(meterReadingsStaging.MutableColumns).
+		DISTINCT(meterReadingsStaging.Nmi, meterReadingsStaging.NmiSuffix, meterReadingsStaging.Timestamp).
+		FROM(meterReadingsStaging).
+		ORDER_BY(meterReadingsStaging.Nmi, meterReadingsStaging.NmiSuffix, meterReadingsStaging.Timestamp, meterReadingsStaging.UpdateDateTime.DESC())
+	insertStmt := table.MeterReadings.
+		INSERT(table.MeterReadings.MutableColumns).
+		QUERY(latestReadings).
+		ON_CONFLICT(table.MeterReadings.Nmi, table.MeterReadings.NmiSuffix, table.MeterReadings.Timestamp).
+		DO_UPDATE(postgres.SET(
+			table.MeterReadings.MutableColumns.SET(row(table.MeterReadings.EXCLUDED.MutableColumns)),
+		).WHERE(table.MeterReadings.UpdateDateTime.LT(table.MeterReadings.EXCLUDED.UpdateDateTime))).
+		RETURNING(insertedRow)
+
+	result, err := upsertRows(ctx, tx, insertStmt, len(readings))
+	if err != nil {
+		return UpsertResult{}, fmt.Errorf("%s: %w", "Failed to merge staging table", err)
+	}
+	return result, tx.Commit()
+}
+
+// meterReadingValues returns the values of a MeterReadings in the order of table.MeterReadings.MutableColumns.
+func meterReadingValues(reading *model.MeterReadings) []any {
+	return []any{
+		reading.Nmi,
+		reading.NmiSuffix,
+		reading.Timestamp,
+		reading.Consumption,
+		reading.Uom,
+		reading.OriginalUom,
+		reading.QualityMethod,
+		reading.ReasonCode,
+		reading.ReasonDescription,
+		reading.UpdateDateTime,
+		reading.MsatsLoadDateTime,
+		reading.FileID,
+	}
+}
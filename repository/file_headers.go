@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+	sql "database/sql"
+	"errors"
+	"sync"
+
+	postgres "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+)
+
+// FileHeaderRepository stores the FileHeaders of NMI files, which the meter readings of a file refer to by its ID.
+type FileHeaderRepository interface {
+	// InsertFileHeader stores the FileHeaders of an NMI file, and sets the ID that it is stored with.
+	InsertFileHeader(ctx context.Context, header *model.FileHeaders) error
+	// FileHeaderByID returns the file header with id, or ErrNotFound when there is none.
+	FileHeaderByID(ctx context.Context, id uuid.UUID) (*model.FileHeaders, error)
+}
+
+// PostgresFileHeaderRepository is a FileHeaderRepository that stores file headers in the file_headers table.
+type PostgresFileHeaderRepository struct {
+	DB *sql.DB
+}
+
+// NewPostgresFileHeaderRepository creates a PostgresFileHeaderRepository that inserts file headers to db.
+func NewPostgresFileHeaderRepository(db *sql.DB) *PostgresFileHeaderRepository {
+	return &PostgresFileHeaderRepository{DB: db}
+}
+
+func (r *PostgresFileHeaderRepository) InsertFileHeader(ctx context.Context, header *model.FileHeaders) error {
+	return InsertFileHeader(ctx, r.DB, header)
+}
+
+func (r *PostgresFileHeaderRepository) FileHeaderByID(ctx context.Context, id uuid.UUID) (*model.FileHeaders, error) {
+	selectStmt := postgres.SELECT(table.FileHeaders.AllColumns).
+		FROM(table.FileHeaders).
+		WHERE(table.FileHeaders.ID.EQ(postgres.UUID(id)))
+
+	header := &model.FileHeaders{}
+	err := selectStmt.QueryContext(ctx, r.DB, header)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// MemoryFileHeaderRepository is a FileHeaderRepository that keeps file headers in memory, for tests that run without a database.
+// It is safe for concurrent use, and keeps copies of the file headers that it receives and returns.
+type MemoryFileHeaderRepository struct {
+	mu      sync.Mutex
+	headers map[uuid.UUID]*model.FileHeaders
+}
+
+// NewMemoryFileHeaderRepository creates an empty MemoryFileHeaderRepository.
+func NewMemoryFileHeaderRepository() *MemoryFileHeaderRepository {
+	return &MemoryFileHeaderRepository{headers: map[uuid.UUID]*model.FileHeaders{}}
+}
+
+func (r *MemoryFileHeaderRepository) InsertFileHeader(ctx context.Context, header *model.FileHeaders) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the ID is generated, as it would be by the database
+	header.ID = uuid.New()
+	stored := *header
+	r.headers[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemoryFileHeaderRepository) FileHeaderByID(ctx context.Context, id uuid.UUID) (*model.FileHeaders, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.headers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *stored
+	return &found, nil
+}
@@ -0,0 +1,60 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+func TestMemoryFileHeaderRepository(t *testing.T) {
+	testFileHeaderRepository(t, repo.NewMemoryFileHeaderRepository())
+}
+
+func TestPostgresFileHeaderRepository(t *testing.T) {
+	db := openTestDB(t)
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM file_headers WHERE file_name = 'TST_file_headers'")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	testFileHeaderRepository(t, repo.NewPostgresFileHeaderRepository(db))
+}
+
+// testFileHeaderRepository checks the behaviour that every FileHeaderRepository shares.
+func testFileHeaderRepository(t *testing.T, repository repo.FileHeaderRepository) {
+	ctx := context.Background()
+	header := &model.FileHeaders{
+		FileName:        "TST_file_headers",
+		VersionHeader:   "NEM12",
+		DateTime:        time.Date(2005, 6, 8, 11, 49, 0, 0, time.UTC),
+		FromParticipant: "UNITEDDP",
+		ToParticipant:   "NEMMCO",
+	}
+	err := repository.InsertFileHeader(ctx, header)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if header.ID == uuid.Nil {
+		t.Errorf("Expected the file header to be given an ID, got %v instead", header.ID)
+	}
+
+	found, err := repository.FileHeaderByID(ctx, header.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if found.ID != header.ID || found.FileName != header.FileName || !found.DateTime.Equal(header.DateTime) ||
+		found.FromParticipant != header.FromParticipant || found.ToParticipant != header.ToParticipant {
+		t.Errorf("Expected %+v, got %+v instead", header, found)
+	}
+
+	_, err = repository.FileHeaderByID(ctx, uuid.New())
+	if !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("Expected %v, got %v instead", repo.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,402 @@
+package repo
+
+import (
+	"context"
+	sql "database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	postgres "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+)
+
+// The statuses of a FileProcessing record, in the order of its lifecycle.
+// A file is received before it is read, and is processing once its 100 record is parsed.
+// It ends as completed when every NMI block was processed, as partial when some NMI blocks failed,
+// or as failed when the file could not be processed.
+// A file with the same content as a completed file is skipped without being processed, and refers to it with DuplicateOf.
+const (
+	FileStatusReceived   = "received"
+	FileStatusProcessing = "processing"
+	FileStatusCompleted  = "completed"
+	FileStatusPartial    = "partial"
+	FileStatusFailed     = "failed"
+	FileStatusSkipped    = "skipped"
+)
+
+// ErrNotFound is returned when a record that is looked up by its ID does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// FileProcessingRepository stores the FileProcessing record that tracks the status of every NMI file,
+// along with the NMI blocks of the file that failed processing.
+type FileProcessingRepository interface {
+	// CreateFileProcessing stores a new FileProcessing record, and sets the ID that it is given.
+	CreateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error
+	// UpdateFileProcessing replaces the stored FileProcessing record with the same ID.
+	UpdateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error
+	// InsertFileProcessingFailures stores the NMI blocks of a file that failed processing.
+	InsertFileProcessingFailures(ctx context.Context, failures []*model.FileProcessingFailures) error
+	// DeleteFileProcessingFailures deletes the failures of ids, and returns the number of failures that were deleted.
+	DeleteFileProcessingFailures(ctx context.Context, ids ...uuid.UUID) (int64, error)
+	// FileProcessingByID returns the FileProcessing record of id, or ErrNotFound.
+	FileProcessingByID(ctx context.Context, id uuid.UUID) (*model.FileProcessing, error)
+	// FileProcessingByFileName returns the FileProcessing records of every time that a file was received, in the order received.
+	FileProcessingByFileName(ctx context.Context, fileName string) ([]*model.FileProcessing, error)
+	// FileProcessingByHash returns the FileProcessing records of every file with the SHA-256 hash fileHash, in the order received.
+	FileProcessingByHash(ctx context.Context, fileHash string) ([]*model.FileProcessing, error)
+	// FileProcessingByStatus returns the FileProcessing records that have one of statuses, in the order received.
+	FileProcessingByStatus(ctx context.Context, statuses ...string) ([]*model.FileProcessing, error)
+	// FileProcessingReceivedBetween returns the FileProcessing records of the files received from from until to, in the order received.
+	FileProcessingReceivedBetween(ctx context.Context, from time.Time, to time.Time) ([]*model.FileProcessing, error)
+	// FileProcessingFailures returns the NMI blocks that failed processing for the FileProcessing record of fileProcessingID.
+	FileProcessingFailures(ctx context.Context, fileProcessingID uuid.UUID) ([]*model.FileProcessingFailures, error)
+}
+
+// PostgresFileProcessingRepository is a FileProcessingRepository that stores records in the file_processing
+// and file_processing_failures tables.
+type PostgresFileProcessingRepository struct {
+	DB            *sql.DB
+	InsertOptions InsertOptions
+}
+
+// NewPostgresFileProcessingRepository creates a PostgresFileProcessingRepository that inserts failures to db with opts.
+func NewPostgresFileProcessingRepository(db *sql.DB, opts InsertOptions) *PostgresFileProcessingRepository {
+	return &PostgresFileProcessingRepository{DB: db, InsertOptions: opts}
+}
+
+func (r *PostgresFileProcessingRepository) CreateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error {
+	insertStmt := table.FileProcessing.
+		INSERT(table.FileProcessing.MutableColumns).
+		MODEL(fileProcessing).
+		RETURNING(table.FileProcessing.ID)
+
+	return insertStmt.QueryContext(ctx, r.DB, fileProcessing)
+}
+
+func (r *PostgresFileProcessingRepository) UpdateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error {
+	updateStmt := table.FileProcessing.
+		UPDATE(table.FileProcessing.MutableColumns).
+		MODEL(fileProcessing).
+		WHERE(table.FileProcessing.ID.EQ(postgres.UUID(fileProcessing.ID)))
+
+	result, err := updateStmt.ExecContext(ctx, r.DB)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresFileProcessingRepository) InsertFileProcessingFailures(ctx context.Context, failures []*model.FileProcessingFailures) error {
+	columns := len(table.FileProcessingFailures.MutableColumns)
+	_, err := insertChunks(ctx, r.DB, r.InsertOptions, table.FileProcessingFailures.TableName(), columns, failures, fileProcessingFailureNmi,
+		func(tx *sql.Tx, chunk []*model.FileProcessingFailures) (UpsertResult, error) {
+			insertStmt := table.FileProcessingFailures.
+				INSERT(table.FileProcessingFailures.MutableColumns).
+				MODELS(chunk)
+
+			_, err := insertStmt.ExecContext(ctx, tx)
+			return UpsertResult{Inserted: int64(len(chunk))}, err
+		})
+	return err
+}
+
+func (r *PostgresFileProcessingRepository) DeleteFileProcessingFailures(ctx context.Context, ids ...uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	values := make([]postgres.Expression, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, postgres.UUID(id))
+	}
+	deleteStmt := table.FileProcessingFailures.
+		DELETE().
+		WHERE(table.FileProcessingFailures.ID.IN(values...))
+
+	result, err := deleteStmt.ExecContext(ctx, r.DB)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingByID(ctx context.Context, id uuid.UUID) (*model.FileProcessing, error) {
+	selectStmt := postgres.SELECT(table.FileProcessing.AllColumns).
+		FROM(table.FileProcessing).
+		WHERE(table.FileProcessing.ID.EQ(postgres.UUID(id)))
+
+	fileProcessing := &model.FileProcessing{}
+	err := selectStmt.QueryContext(ctx, r.DB, fileProcessing)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fileProcessing, nil
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingByFileName(ctx context.Context, fileName string) ([]*model.FileProcessing, error) {
+	selectStmt := postgres.SELECT(table.FileProcessing.AllColumns).
+		FROM(table.FileProcessing).
+		WHERE(table.FileProcessing.FileName.EQ(postgres.String(fileName))).
+		ORDER_BY(table.FileProcessing.ReceivedAt)
+
+	fileProcessing := []*model.FileProcessing{}
+	err := selectStmt.QueryContext(ctx, r.DB, &fileProcessing)
+	return fileProcessing, err
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingByHash(ctx context.Context, fileHash string) ([]*model.FileProcessing, error) {
+	selectStmt := postgres.SELECT(table.FileProcessing.AllColumns).
+		FROM(table.FileProcessing).
+		WHERE(table.FileProcessing.FileHash.EQ(postgres.String(fileHash))).
+		ORDER_BY(table.FileProcessing.ReceivedAt)
+
+	fileProcessing := []*model.FileProcessing{}
+	err := selectStmt.QueryContext(ctx, r.DB, &fileProcessing)
+	return fileProcessing, err
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingByStatus(ctx context.Context, statuses ...string) ([]*model.FileProcessing, error) {
+	fileProcessing := []*model.FileProcessing{}
+	if len(statuses) == 0 {
+		return fileProcessing, nil
+	}
+	values := make([]postgres.Expression, 0, len(statuses))
+	for _, status := range statuses {
+		values = append(values, postgres.String(status))
+	}
+	selectStmt := postgres.SELECT(table.FileProcessing.AllColumns).
+		FROM(table.FileProcessing).
+		WHERE(table.FileProcessing.Status.IN(values...)).
+		ORDER_BY(table.FileProcessing.ReceivedAt)
+
+	err := selectStmt.QueryContext(ctx, r.DB, &fileProcessing)
+	return fileProcessing, err
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingReceivedBetween(ctx context.Context, from time.Time, to time.Time) ([]*model.FileProcessing, error) {
+	selectStmt := postgres.SELECT(table.FileProcessing.AllColumns).
+		FROM(table.FileProcessing).
+		WHERE(table.FileProcessing.ReceivedAt.GT_EQ(postgres.TimestampT(from)).
+			AND(table.FileProcessing.ReceivedAt.LT(postgres.TimestampT(to)))).
+		ORDER_BY(table.FileProcessing.ReceivedAt)
+
+	fileProcessing := []*model.FileProcessing{}
+	err := selectStmt.QueryContext(ctx, r.DB, &fileProcessing)
+	return fileProcessing, err
+}
+
+func (r *PostgresFileProcessingRepository) FileProcessingFailures(ctx context.Context, fileProcessingID uuid.UUID) ([]*model.FileProcessingFailures, error) {
+	selectStmt := postgres.SELECT(table.FileProcessingFailures.AllColumns).
+		FROM(table.FileProcessingFailures).
+		WHERE(table.FileProcessingFailures.FileProcessingID.EQ(postgres.UUID(fileProcessingID))).
+		ORDER_BY(table.FileProcessingFailures.Nmi)
+
+	failures := []*model.FileProcessingFailures{}
+	err := selectStmt.QueryContext(ctx, r.DB, &failures)
+	return failures, err
+}
+
+// MemoryFileProcessingRepository is a FileProcessingRepository that keeps records in memory, for tests that run without a database.
+// It is safe for concurrent use, and keeps copies of the records that it receives and returns.
+type MemoryFileProcessingRepository struct {
+	mu sync.Mutex
+	// fileProcessing is kept in the order that the records were created
+	fileProcessing []*model.FileProcessing
+	failures       []*model.FileProcessingFailures
+}
+
+// NewMemoryFileProcessingRepository creates an empty MemoryFileProcessingRepository.
+func NewMemoryFileProcessingRepository() *MemoryFileProcessingRepository {
+	return &MemoryFileProcessingRepository{}
+}
+
+func (r *MemoryFileProcessingRepository) CreateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the ID is generated, as it would be by the database
+	fileProcessing.ID = uuid.New()
+	stored := *fileProcessing
+	r.fileProcessing = append(r.fileProcessing, &stored)
+	return nil
+}
+
+func (r *MemoryFileProcessingRepository) UpdateFileProcessing(ctx context.Context, fileProcessing *model.FileProcessing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, stored := range r.fileProcessing {
+		if stored.ID == fileProcessing.ID {
+			updated := *fileProcessing
+			r.fileProcessing[i] = &updated
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryFileProcessingRepository) InsertFileProcessingFailures(ctx context.Context, failures []*model.FileProcessingFailures) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, failure := range failures {
+		stored := *failure
+		stored.ID = uuid.New()
+		r.failures = append(r.failures, &stored)
+	}
+	return nil
+}
+
+func (r *MemoryFileProcessingRepository) DeleteFileProcessingFailures(ctx context.Context, ids ...uuid.UUID) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	kept := r.failures[:0]
+	for _, failure := range r.failures {
+		if !deleted[failure.ID] {
+			kept = append(kept, failure)
+		}
+	}
+	count := int64(len(r.failures) - len(kept))
+	r.failures = kept
+	return count, nil
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingByID(ctx context.Context, id uuid.UUID) (*model.FileProcessing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.fileProcessing {
+		if stored.ID == id {
+			found := *stored
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingByFileName(ctx context.Context, fileName string) ([]*model.FileProcessing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fileProcessing := []*model.FileProcessing{}
+	for _, stored := range r.fileProcessing {
+		if stored.FileName == fileName {
+			found := *stored
+			fileProcessing = append(fileProcessing, &found)
+		}
+	}
+	return fileProcessing, nil
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingByHash(ctx context.Context, fileHash string) ([]*model.FileProcessing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fileProcessing := []*model.FileProcessing{}
+	for _, stored := range r.fileProcessing {
+		if stored.FileHash != nil && *stored.FileHash == fileHash {
+			found := *stored
+			fileProcessing = append(fileProcessing, &found)
+		}
+	}
+	return fileProcessing, nil
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingByStatus(ctx context.Context, statuses ...string) ([]*model.FileProcessing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fileProcessing := []*model.FileProcessing{}
+	for _, stored := range r.fileProcessing {
+		for _, status := range statuses {
+			if stored.Status == status {
+				found := *stored
+				fileProcessing = append(fileProcessing, &found)
+				break
+			}
+		}
+	}
+	return fileProcessing, nil
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingReceivedBetween(ctx context.Context, from time.Time, to time.Time) ([]*model.FileProcessing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fileProcessing := []*model.FileProcessing{}
+	for _, stored := range r.fileProcessing {
+		if !stored.ReceivedAt.Before(from) && stored.ReceivedAt.Before(to) {
+			found := *stored
+			fileProcessing = append(fileProcessing, &found)
+		}
+	}
+	return fileProcessing, nil
+}
+
+func (r *MemoryFileProcessingRepository) FileProcessingFailures(ctx context.Context, fileProcessingID uuid.UUID) ([]*model.FileProcessingFailures, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures := []*model.FileProcessingFailures{}
+	for _, failure := range r.failures {
+		if failure.FileProcessingID == fileProcessingID {
+			found := *failure
+			failures = append(failures, &found)
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Nmi < failures[j].Nmi
+	})
+	return failures, nil
+}
+
+func fileProcessingFailureNmi(failure *model.FileProcessingFailures) string { return failure.Nmi }
@@ -0,0 +1,157 @@
+package repo_test
+
+import (
+	"context"
+	sql "database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+func TestMemoryFileProcessingRepository(t *testing.T) {
+	testFileProcessingRepository(t, repo.NewMemoryFileProcessingRepository())
+}
+
+func TestPostgresFileProcessingRepository(t *testing.T) {
+	db := openTestDB(t)
+	deleteTestFileProcessing(t, db)
+	t.Cleanup(func() { deleteTestFileProcessing(t, db) })
+	testFileProcessingRepository(t, repo.NewPostgresFileProcessingRepository(db, repo.InsertOptions{}))
+}
+
+// testFileProcessingRepository checks the behaviour that every FileProcessingRepository shares.
+func testFileProcessingRepository(t *testing.T, repository repo.FileProcessingRepository) {
+	ctx := context.Background()
+	receivedAt := time.Date(2005, 6, 8, 12, 0, 0, 0, time.UTC)
+	record := &model.FileProcessing{FileName: "TST_file_processing.csv", Status: repo.FileStatusReceived, ReceivedAt: receivedAt}
+	err := repository.CreateFileProcessing(ctx, record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	finishedAt := receivedAt.Add(time.Minute)
+	record.Status = repo.FileStatusPartial
+	record.FinishedAt = &finishedAt
+	record.ParsedCount, record.InsertedCount, record.FailedCount = 1, 8, 1
+	fileHash := strings.Repeat("0", 64)
+	record.FileHash = &fileHash
+	err = repository.UpdateFileProcessing(ctx, record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	err = repository.InsertFileProcessingFailures(ctx, []*model.FileProcessingFailures{
+		{FileProcessingID: record.ID, Nmi: "TST0000002", Errors: "nmi TST0000002 has checksum 9, expected 0"},
+		{FileProcessingID: record.ID, Nmi: "TST0000001", Errors: "nmi TST0000001 contains the letter O"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+
+	found, err := repository.FileProcessingByID(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if found.Status != repo.FileStatusPartial || found.InsertedCount != 8 || found.FinishedAt == nil || !found.FinishedAt.Equal(finishedAt) {
+		t.Errorf("Expected %+v, got %+v instead", record, found)
+	}
+	records, err := repository.FileProcessingByFileName(ctx, "TST_file_processing.csv")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(records) != 1 || records[0].ID != record.ID {
+		t.Errorf("Expected the record %v, got %+v instead", record.ID, records)
+	}
+	records, err = repository.FileProcessingByHash(ctx, fileHash)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(records) != 1 || records[0].ID != record.ID {
+		t.Errorf("Expected the record %v, got %+v instead", record.ID, records)
+	}
+	failures, err := repository.FileProcessingFailures(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(failures) != 2 || failures[0].Nmi != "TST0000001" || failures[1].Nmi != "TST0000002" {
+		t.Fatalf("Expected the failures of TST0000001 and TST0000002, got %+v instead", failures)
+	}
+	deleted, err := repository.DeleteFileProcessingFailures(ctx, failures[0].ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 deleted failure, got %v instead", deleted)
+	}
+	failures, err = repository.FileProcessingFailures(ctx, record.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(failures) != 1 || failures[0].Nmi != "TST0000002" {
+		t.Errorf("Expected the failure of TST0000002, got %+v instead", failures)
+	}
+	records, err = repository.FileProcessingByStatus(ctx, repo.FileStatusProcessing, repo.FileStatusPartial)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if records = testFileProcessingRecords(records); len(records) != 1 || records[0].ID != record.ID {
+		t.Errorf("Expected the record %v, got %+v instead", record.ID, records)
+	}
+	records, err = repository.FileProcessingByStatus(ctx, repo.FileStatusCompleted)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if records = testFileProcessingRecords(records); len(records) != 0 {
+		t.Errorf("Expected no records, got %+v instead", records)
+	}
+	records, err = repository.FileProcessingReceivedBetween(ctx, receivedAt, receivedAt.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if records = testFileProcessingRecords(records); len(records) != 1 || records[0].ID != record.ID {
+		t.Errorf("Expected the record %v, got %+v instead", record.ID, records)
+	}
+	records, err = repository.FileProcessingReceivedBetween(ctx, receivedAt.Add(time.Second), receivedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if records = testFileProcessingRecords(records); len(records) != 0 {
+		t.Errorf("Expected no records, got %+v instead", records)
+	}
+
+	record.ID[0]++
+	err = repository.UpdateFileProcessing(ctx, record)
+	if !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("Expected %v, got %v instead", repo.ErrNotFound, err)
+	}
+	_, err = repository.FileProcessingByID(ctx, record.ID)
+	if !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("Expected %v, got %v instead", repo.ErrNotFound, err)
+	}
+}
+
+// testFileProcessingRecords keeps the records of test files, as a database can also hold the records of other files.
+func testFileProcessingRecords(records []*model.FileProcessing) []*model.FileProcessing {
+	found := []*model.FileProcessing{}
+	for _, record := range records {
+		if strings.HasPrefix(record.FileName, "TST") {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+func deleteTestFileProcessing(tb testing.TB, db *sql.DB) {
+	tb.Helper()
+	_, err := db.Exec("DELETE FROM file_processing_failures WHERE nmi LIKE 'TST%'")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	_, err = db.Exec("DELETE FROM file_processing WHERE file_name LIKE 'TST%'")
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	sql "database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	postgres "github.com/go-jet/jet/v2/postgres"
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+)
+
+// MeterReadingRepository stores MeterReadings, so that callers do not depend on the database that the readings are kept in.
+type MeterReadingRepository interface {
+	// UpsertMeterReadings stores a list of MeterReadings with the precedence of BulkUpsertMeterReadings,
+	// where a meter reading only replaces the stored meter reading of its natural key when its UpdateDateTime is later.
+	UpsertMeterReadings(ctx context.Context, readings []*model.MeterReadings) (UpsertResult, error)
+	// MeterReadingsByNmi returns the meter readings of a NMI with a timestamp from the start of from until the start of to,
+	// ordered by NMISuffix and timestamp.
+	MeterReadingsByNmi(ctx context.Context, nmi string, from time.Time, to time.Time) ([]*model.MeterReadings, error)
+	// DeleteMeterReadingsByFile deletes the meter readings that were last stored from the NMI file of fileID,
+	// and returns the number of meter readings that were deleted.
+	DeleteMeterReadingsByFile(ctx context.Context, fileID uuid.UUID) (int64, error)
+}
+
+// PostgresMeterReadingRepository is a MeterReadingRepository that stores meter readings in the meter_readings table.
+type PostgresMeterReadingRepository struct {
+	DB            *sql.DB
+	InsertOptions InsertOptions
+}
+
+// NewPostgresMeterReadingRepository creates a PostgresMeterReadingRepository that upserts meter readings to db with opts.
+func NewPostgresMeterReadingRepository(db *sql.DB, opts InsertOptions) *PostgresMeterReadingRepository {
+	return &PostgresMeterReadingRepository{DB: db, InsertOptions: opts}
+}
+
+func (r *PostgresMeterReadingRepository) UpsertMeterReadings(ctx context.Context, readings []*model.MeterReadings) (UpsertResult, error) {
+	return BulkUpsertMeterReadings(ctx, r.DB, readings, r.InsertOptions)
+}
+
+func (r *PostgresMeterReadingRepository) MeterReadingsByNmi(ctx context.Context, nmi string, from time.Time, to time.Time) ([]*model.MeterReadings, error) {
+	selectStmt := postgres.SELECT(table.MeterReadings.AllColumns).
+		FROM(table.MeterReadings).
+		WHERE(table.MeterReadings.Nmi.EQ(postgres.String(nmi)).
+			AND(table.MeterReadings.Timestamp.GT_EQ(postgres.TimestampT(from))).
+			AND(table.MeterReadings.Timestamp.LT(postgres.TimestampT(to)))).
+		ORDER_BY(table.MeterReadings.NmiSuffix, table.MeterReadings.Timestamp)
+
+	readings := []*model.MeterReadings{}
+	err := selectStmt.QueryContext(ctx, r.DB, &readings)
+	return readings, err
+}
+
+func (r *PostgresMeterReadingRepository) DeleteMeterReadingsByFile(ctx context.Context, fileID uuid.UUID) (int64, error) {
+	deleteStmt := table.MeterReadings.
+		DELETE().
+		WHERE(table.MeterReadings.FileID.EQ(postgres.UUID(fileID)))
+
+	result, err := deleteStmt.ExecContext(ctx, r.DB)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MemoryMeterReadingRepository is a MeterReadingRepository that keeps meter readings in memory, for tests that run without a database.
+// It is safe for concurrent use, and keeps copies of the meter readings that it receives and returns.
+type MemoryMeterReadingRepository struct {
+	mu       sync.Mutex
+	readings map[meterReadingKey]*model.MeterReadings
+}
+
+// NewMemoryMeterReadingRepository creates an empty MemoryMeterReadingRepository.
+func NewMemoryMeterReadingRepository() *MemoryMeterReadingRepository {
+	return &MemoryMeterReadingRepository{readings: map[meterReadingKey]*model.MeterReadings{}}
+}
+
+func (r *MemoryMeterReadingRepository) UpsertMeterReadings(ctx context.Context, readings []*model.MeterReadings) (UpsertResult, error) {
+	if err := ctx.Err(); err != nil {
+		return UpsertResult{}, err
+	}
+	uniqueReadings := latestRows(readings, meterReadingNaturalKey, meterReadingUpdateDateTime)
+	result := UpsertResult{Skipped: int64(len(readings) - len(uniqueReadings))}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, reading := range uniqueReadings {
+		stored := *reading
+		key := meterReadingNaturalKey(reading)
+		existing, ok := r.readings[key]
+		switch {
+		case !ok:
+			// the ID is generated, as it would be by the database
+			stored.ID = uuid.New()
+			result.Inserted++
+		case existing.UpdateDateTime.Before(reading.UpdateDateTime):
+			stored.ID = existing.ID
+			result.Updated++
+		default:
+			result.Skipped++
+			continue
+		}
+		r.readings[key] = &stored
+	}
+	return result, nil
+}
+
+func (r *MemoryMeterReadingRepository) MeterReadingsByNmi(ctx context.Context, nmi string, from time.Time, to time.Time) ([]*model.MeterReadings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	readings := []*model.MeterReadings{}
+	for _, reading := range r.readings {
+		if reading.Nmi == nmi && !reading.Timestamp.Before(from) && reading.Timestamp.Before(to) {
+			found := *reading
+			readings = append(readings, &found)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].NmiSuffix != readings[j].NmiSuffix {
+			return readings[i].NmiSuffix < readings[j].NmiSuffix
+		}
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+	return readings, nil
+}
+
+func (r *MemoryMeterReadingRepository) DeleteMeterReadingsByFile(ctx context.Context, fileID uuid.UUID) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, reading := range r.readings {
+		if reading.FileID != nil && *reading.FileID == fileID {
+			delete(r.readings, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,126 @@
+package repo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+func TestMemoryMeterReadingRepository(t *testing.T) {
+	testMeterReadingRepository(t, repo.NewMemoryMeterReadingRepository(), func(t *testing.T) uuid.UUID {
+		return uuid.New()
+	})
+}
+
+func TestPostgresMeterReadingRepository(t *testing.T) {
+	db := openTestDB(t)
+	t.Cleanup(func() {
+		// the meter readings refer to the file headers, so they are deleted first
+		deleteTestMeterReadings(t, db)
+		_, err := db.Exec("DELETE FROM file_headers WHERE file_name = 'TST_repository'")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	repository := repo.NewPostgresMeterReadingRepository(db, repo.InsertOptions{})
+	testMeterReadingRepository(t, repository, func(t *testing.T) uuid.UUID {
+		header := &model.FileHeaders{
+			FileName:        "TST_repository",
+			VersionHeader:   "NEM12",
+			DateTime:        time.Date(2005, 6, 8, 11, 49, 0, 0, time.UTC),
+			FromParticipant: "UNITEDDP",
+			ToParticipant:   "NEMMCO",
+		}
+		err := repo.InsertFileHeader(context.Background(), db, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return header.ID
+	})
+}
+
+// testMeterReadingRepository checks the behaviour that every MeterReadingRepository shares,
+// with newFile creating the ID of an NMI file that meter readings can refer to.
+func testMeterReadingRepository(t *testing.T, repository repo.MeterReadingRepository, newFile func(t *testing.T) uuid.UUID) {
+	ctx := context.Background()
+	day := func(d int) time.Time { return time.Date(2005, 3, d, 0, 0, 0, 0, time.UTC) }
+	updated := time.Date(2005, 3, 10, 12, 10, 4, 0, time.UTC)
+	reading := func(d int, consumption string, updateDateTime time.Time, fileID uuid.UUID) *model.MeterReadings {
+		return &model.MeterReadings{
+			Nmi:            "TST0000001",
+			NmiSuffix:      "E1",
+			Timestamp:      day(d),
+			Consumption:    decimal.MustParse(consumption),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			QualityMethod:  "A",
+			UpdateDateTime: updateDateTime,
+			FileID:         &fileID,
+		}
+	}
+
+	original, revision := newFile(t), newFile(t)
+	result, err := repository.UpsertMeterReadings(ctx, []*model.MeterReadings{
+		reading(1, "1.5", updated, original),
+		reading(2, "2.5", updated, original),
+		reading(3, "3.5", updated, original),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 3}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+
+	// a revised reading replaces the stored reading, while an older reading is skipped
+	result, err = repository.UpsertMeterReadings(ctx, []*model.MeterReadings{
+		reading(2, "4.5", updated.Add(time.Hour), revision),
+		reading(3, "5.5", updated.Add(-time.Hour), revision),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Updated: 1, Skipped: 1}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+
+	readings, err := repository.MeterReadingsByNmi(ctx, "TST0000001", day(1), day(3))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	expected := []*model.MeterReadings{reading(1, "1.5", updated, original), reading(2, "4.5", updated.Add(time.Hour), revision)}
+	assertMeterReadings(t, expected, readings)
+
+	deleted, err := repository.DeleteMeterReadingsByFile(ctx, original)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 readings to be deleted, got %v instead", deleted)
+	}
+	readings, err = repository.MeterReadingsByNmi(ctx, "TST0000001", day(1), day(4))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	assertMeterReadings(t, expected[1:], readings)
+}
+
+// assertMeterReadings compares the natural key, consumption and file of meter readings, as their IDs are generated.
+func assertMeterReadings(t *testing.T, expected []*model.MeterReadings, got []*model.MeterReadings) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("Expected %v readings, got %v instead", len(expected), len(got))
+	}
+	for i := range expected {
+		if expected[i].Nmi != got[i].Nmi || !expected[i].Timestamp.Equal(got[i].Timestamp) ||
+			expected[i].Consumption != got[i].Consumption || got[i].FileID == nil || *expected[i].FileID != *got[i].FileID {
+			t.Errorf("Expected %+v, got %+v instead", expected[i], got[i])
+		}
+	}
+}
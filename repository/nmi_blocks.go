@@ -0,0 +1,336 @@
+package repo
+
+import (
+	"context"
+	sql "database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	postgres "github.com/go-jet/jet/v2/postgres"
+	"github.com/google/uuid"
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+)
+
+// NmiBlockRepository stores the data streams, interval readings, B2B details and accumulation readings of NMI blocks,
+// which are the models of an NMI block other than its meter readings.
+type NmiBlockRepository interface {
+	// UpsertDataStreams stores a list of DataStreams with the precedence of BulkUpsertDataStreams,
+	// where the latest details of a data stream replace the stored ones.
+	UpsertDataStreams(ctx context.Context, dataStreams []*model.DataStreams) error
+	// UpsertIntervalReadings stores a list of IntervalReadings with the precedence of BulkUpsertIntervalReadings,
+	// where an interval reading only replaces the stored interval reading of its natural key when its UpdateDateTime is later.
+	UpsertIntervalReadings(ctx context.Context, readings []*model.IntervalReadings) (UpsertResult, error)
+	// InsertB2bDetails stores a list of B2bDetails with the precedence of BulkInsertB2bDetails,
+	// where a B2B detail is skipped when a B2B detail with the same natural key is already stored.
+	InsertB2bDetails(ctx context.Context, b2bDetails []*model.B2bDetails) (UpsertResult, error)
+	// UpsertAccumulationReadings stores a list of AccumulationReadings with the precedence of BulkUpsertAccumulationReadings,
+	// where an accumulation reading only replaces the stored accumulation reading of its natural key when its UpdateDateTime is later.
+	UpsertAccumulationReadings(ctx context.Context, readings []*model.AccumulationReadings) (UpsertResult, error)
+	// DataStreamsByNmi returns the data streams of a NMI, ordered by NMISuffix.
+	DataStreamsByNmi(ctx context.Context, nmi string) ([]*model.DataStreams, error)
+	// IntervalReadingsByNmi returns the interval readings of a NMI, ordered by NMISuffix and interval start.
+	IntervalReadingsByNmi(ctx context.Context, nmi string) ([]*model.IntervalReadings, error)
+	// B2bDetailsByNmi returns the B2B details of a NMI, ordered by NMISuffix and retailer service order.
+	B2bDetailsByNmi(ctx context.Context, nmi string) ([]*model.B2bDetails, error)
+	// AccumulationReadingsByNmi returns the accumulation readings of a NMI, ordered by NMISuffix and current register read date time.
+	AccumulationReadingsByNmi(ctx context.Context, nmi string) ([]*model.AccumulationReadings, error)
+}
+
+// PostgresNmiBlockRepository is a NmiBlockRepository that stores the models of NMI blocks in their tables.
+type PostgresNmiBlockRepository struct {
+	DB            *sql.DB
+	InsertOptions InsertOptions
+}
+
+// NewPostgresNmiBlockRepository creates a PostgresNmiBlockRepository that inserts to db with opts.
+func NewPostgresNmiBlockRepository(db *sql.DB, opts InsertOptions) *PostgresNmiBlockRepository {
+	return &PostgresNmiBlockRepository{DB: db, InsertOptions: opts}
+}
+
+func (r *PostgresNmiBlockRepository) UpsertDataStreams(ctx context.Context, dataStreams []*model.DataStreams) error {
+	return BulkUpsertDataStreams(ctx, r.DB, dataStreams, r.InsertOptions)
+}
+
+func (r *PostgresNmiBlockRepository) UpsertIntervalReadings(ctx context.Context, readings []*model.IntervalReadings) (UpsertResult, error) {
+	return BulkUpsertIntervalReadings(ctx, r.DB, readings, r.InsertOptions)
+}
+
+func (r *PostgresNmiBlockRepository) InsertB2bDetails(ctx context.Context, b2bDetails []*model.B2bDetails) (UpsertResult, error) {
+	return BulkInsertB2bDetails(ctx, r.DB, b2bDetails, r.InsertOptions)
+}
+
+func (r *PostgresNmiBlockRepository) UpsertAccumulationReadings(ctx context.Context, readings []*model.AccumulationReadings) (UpsertResult, error) {
+	return BulkUpsertAccumulationReadings(ctx, r.DB, readings, r.InsertOptions)
+}
+
+func (r *PostgresNmiBlockRepository) DataStreamsByNmi(ctx context.Context, nmi string) ([]*model.DataStreams, error) {
+	selectStmt := postgres.SELECT(table.DataStreams.AllColumns).
+		FROM(table.DataStreams).
+		WHERE(table.DataStreams.Nmi.EQ(postgres.String(nmi))).
+		ORDER_BY(table.DataStreams.NmiSuffix)
+
+	dataStreams := []*model.DataStreams{}
+	err := selectStmt.QueryContext(ctx, r.DB, &dataStreams)
+	return dataStreams, err
+}
+
+func (r *PostgresNmiBlockRepository) IntervalReadingsByNmi(ctx context.Context, nmi string) ([]*model.IntervalReadings, error) {
+	selectStmt := postgres.SELECT(table.IntervalReadings.AllColumns).
+		FROM(table.IntervalReadings).
+		WHERE(table.IntervalReadings.Nmi.EQ(postgres.String(nmi))).
+		ORDER_BY(table.IntervalReadings.NmiSuffix, table.IntervalReadings.IntervalStart)
+
+	readings := []*model.IntervalReadings{}
+	err := selectStmt.QueryContext(ctx, r.DB, &readings)
+	return readings, err
+}
+
+func (r *PostgresNmiBlockRepository) B2bDetailsByNmi(ctx context.Context, nmi string) ([]*model.B2bDetails, error) {
+	selectStmt := postgres.SELECT(table.B2bDetails.AllColumns).
+		FROM(table.B2bDetails).
+		WHERE(table.B2bDetails.Nmi.EQ(postgres.String(nmi))).
+		ORDER_BY(table.B2bDetails.NmiSuffix, table.B2bDetails.RetServiceOrder)
+
+	b2bDetails := []*model.B2bDetails{}
+	err := selectStmt.QueryContext(ctx, r.DB, &b2bDetails)
+	return b2bDetails, err
+}
+
+func (r *PostgresNmiBlockRepository) AccumulationReadingsByNmi(ctx context.Context, nmi string) ([]*model.AccumulationReadings, error) {
+	selectStmt := postgres.SELECT(table.AccumulationReadings.AllColumns).
+		FROM(table.AccumulationReadings).
+		WHERE(table.AccumulationReadings.Nmi.EQ(postgres.String(nmi))).
+		ORDER_BY(table.AccumulationReadings.NmiSuffix, table.AccumulationReadings.CurrentRegisterReadDateTime)
+
+	readings := []*model.AccumulationReadings{}
+	err := selectStmt.QueryContext(ctx, r.DB, &readings)
+	return readings, err
+}
+
+// MemoryNmiBlockRepository is a NmiBlockRepository that keeps the models of NMI blocks in memory, for tests that run without a database.
+// It is safe for concurrent use, and keeps copies of the models that it receives and returns.
+type MemoryNmiBlockRepository struct {
+	mu                   sync.Mutex
+	dataStreams          map[[2]string]*model.DataStreams
+	intervalReadings     map[meterReadingKey]*model.IntervalReadings
+	b2bDetails           []*model.B2bDetails
+	b2bDetailKeys        map[b2bDetailKey]bool
+	accumulationReadings map[meterReadingKey]*model.AccumulationReadings
+}
+
+// NewMemoryNmiBlockRepository creates an empty MemoryNmiBlockRepository.
+func NewMemoryNmiBlockRepository() *MemoryNmiBlockRepository {
+	return &MemoryNmiBlockRepository{
+		dataStreams:          map[[2]string]*model.DataStreams{},
+		intervalReadings:     map[meterReadingKey]*model.IntervalReadings{},
+		b2bDetailKeys:        map[b2bDetailKey]bool{},
+		accumulationReadings: map[meterReadingKey]*model.AccumulationReadings{},
+	}
+}
+
+func (r *MemoryNmiBlockRepository) UpsertDataStreams(ctx context.Context, dataStreams []*model.DataStreams) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, dataStream := range dataStreams {
+		stored := *dataStream
+		key := [2]string{dataStream.Nmi, dataStream.NmiSuffix}
+		if existing, ok := r.dataStreams[key]; ok {
+			stored.ID = existing.ID
+		} else {
+			// the ID is generated, as it would be by the database
+			stored.ID = uuid.New()
+		}
+		r.dataStreams[key] = &stored
+	}
+	return nil
+}
+
+func (r *MemoryNmiBlockRepository) UpsertIntervalReadings(ctx context.Context, readings []*model.IntervalReadings) (UpsertResult, error) {
+	if err := ctx.Err(); err != nil {
+		return UpsertResult{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return upsertMemoryRows(r.intervalReadings, readings, intervalReadingNaturalKey, intervalReadingUpdateDateTime,
+		func(reading *model.IntervalReadings) *uuid.UUID { return &reading.ID }), nil
+}
+
+func (r *MemoryNmiBlockRepository) InsertB2bDetails(ctx context.Context, b2bDetails []*model.B2bDetails) (UpsertResult, error) {
+	if err := ctx.Err(); err != nil {
+		return UpsertResult{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := UpsertResult{}
+	for _, details := range b2bDetails {
+		key := b2bDetailNaturalKey(details)
+		if r.b2bDetailKeys[key] {
+			result.Skipped++
+			continue
+		}
+		result.Inserted++
+		r.b2bDetailKeys[key] = true
+		stored := *details
+		stored.ID = uuid.New()
+		r.b2bDetails = append(r.b2bDetails, &stored)
+	}
+	return result, nil
+}
+
+func (r *MemoryNmiBlockRepository) UpsertAccumulationReadings(ctx context.Context, readings []*model.AccumulationReadings) (UpsertResult, error) {
+	if err := ctx.Err(); err != nil {
+		return UpsertResult{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return upsertMemoryRows(r.accumulationReadings, readings, accumulationReadingNaturalKey, accumulationReadingUpdateDateTime,
+		func(reading *model.AccumulationReadings) *uuid.UUID { return &reading.ID }), nil
+}
+
+func (r *MemoryNmiBlockRepository) DataStreamsByNmi(ctx context.Context, nmi string) ([]*model.DataStreams, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dataStreams := []*model.DataStreams{}
+	for _, dataStream := range r.dataStreams {
+		if dataStream.Nmi == nmi {
+			found := *dataStream
+			dataStreams = append(dataStreams, &found)
+		}
+	}
+	sort.Slice(dataStreams, func(i, j int) bool {
+		return dataStreams[i].NmiSuffix < dataStreams[j].NmiSuffix
+	})
+	return dataStreams, nil
+}
+
+func (r *MemoryNmiBlockRepository) IntervalReadingsByNmi(ctx context.Context, nmi string) ([]*model.IntervalReadings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	readings := []*model.IntervalReadings{}
+	for _, reading := range r.intervalReadings {
+		if reading.Nmi == nmi {
+			found := *reading
+			readings = append(readings, &found)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].NmiSuffix != readings[j].NmiSuffix {
+			return readings[i].NmiSuffix < readings[j].NmiSuffix
+		}
+		return readings[i].IntervalStart.Before(readings[j].IntervalStart)
+	})
+	return readings, nil
+}
+
+func (r *MemoryNmiBlockRepository) B2bDetailsByNmi(ctx context.Context, nmi string) ([]*model.B2bDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b2bDetails := []*model.B2bDetails{}
+	for _, details := range r.b2bDetails {
+		if details.Nmi == nmi {
+			found := *details
+			b2bDetails = append(b2bDetails, &found)
+		}
+	}
+	sort.SliceStable(b2bDetails, func(i, j int) bool {
+		if b2bDetails[i].NmiSuffix != b2bDetails[j].NmiSuffix {
+			return b2bDetails[i].NmiSuffix < b2bDetails[j].NmiSuffix
+		}
+		return b2bDetails[i].RetServiceOrder < b2bDetails[j].RetServiceOrder
+	})
+	return b2bDetails, nil
+}
+
+func (r *MemoryNmiBlockRepository) AccumulationReadingsByNmi(ctx context.Context, nmi string) ([]*model.AccumulationReadings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	readings := []*model.AccumulationReadings{}
+	for _, reading := range r.accumulationReadings {
+		if reading.Nmi == nmi {
+			found := *reading
+			readings = append(readings, &found)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].NmiSuffix != readings[j].NmiSuffix {
+			return readings[i].NmiSuffix < readings[j].NmiSuffix
+		}
+		return readings[i].CurrentRegisterReadDateTime.Before(readings[j].CurrentRegisterReadDateTime)
+	})
+	return readings, nil
+}
+
+// upsertMemoryRows upserts rows into stored by their natural key, with the precedence of the bulk upserts,
+// where a row only replaces the stored row of its key when its UpdateDateTime is later.
+// A row that is inserted is given a generated ID, and a row that is updated keeps the ID of the row it replaces.
+func upsertMemoryRows[T any](stored map[meterReadingKey]*T, rows []*T, key func(*T) meterReadingKey, updateDateTime func(*T) time.Time, id func(*T) *uuid.UUID) UpsertResult {
+	uniqueRows := latestRows(rows, key, updateDateTime)
+	result := UpsertResult{Skipped: int64(len(rows) - len(uniqueRows))}
+	for _, row := range uniqueRows {
+		copied := *row
+		k := key(row)
+		existing, ok := stored[k]
+		switch {
+		case !ok:
+			*id(&copied) = uuid.New()
+			result.Inserted++
+		case updateDateTime(existing).Before(updateDateTime(row)):
+			*id(&copied) = *id(existing)
+			result.Updated++
+		default:
+			result.Skipped++
+			continue
+		}
+		stored[k] = &copied
+	}
+	return result
+}
+
+// b2bDetailKey is the natural key of B2B details, where a B2B detail without a read date time has the zero time.
+type b2bDetailKey struct {
+	nmi             string
+	nmiSuffix       string
+	retServiceOrder string
+	readDateTime    time.Time
+}
+
+func b2bDetailNaturalKey(details *model.B2bDetails) b2bDetailKey {
+	key := b2bDetailKey{nmi: details.Nmi, nmiSuffix: details.NmiSuffix, retServiceOrder: details.RetServiceOrder}
+	if details.ReadDateTime != nil {
+		key.readDateTime = *details.ReadDateTime
+	}
+	return key
+}
+
+func intervalReadingNaturalKey(reading *model.IntervalReadings) meterReadingKey {
+	return meterReadingKey{reading.Nmi, reading.NmiSuffix, reading.IntervalStart}
+}
+
+func accumulationReadingNaturalKey(reading *model.AccumulationReadings) meterReadingKey {
+	return meterReadingKey{reading.Nmi, reading.NmiSuffix, reading.CurrentRegisterReadDateTime}
+}
@@ -0,0 +1,174 @@
+package repo_test
+
+import (
+	"context"
+	sql "database/sql"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	"github.com/ts33/energy-reading/decimal"
+	repo "github.com/ts33/energy-reading/repository"
+)
+
+func TestMemoryNmiBlockRepository(t *testing.T) {
+	testNmiBlockRepository(t, repo.NewMemoryNmiBlockRepository())
+}
+
+func TestPostgresNmiBlockRepository(t *testing.T) {
+	db := openTestDB(t)
+	deleteTestNmiBlocks(t, db)
+	t.Cleanup(func() { deleteTestNmiBlocks(t, db) })
+	testNmiBlockRepository(t, repo.NewPostgresNmiBlockRepository(db, repo.InsertOptions{}))
+}
+
+// testNmiBlockRepository checks the behaviour that every NmiBlockRepository shares.
+func testNmiBlockRepository(t *testing.T, repository repo.NmiBlockRepository) {
+	ctx := context.Background()
+	updateDateTime := time.Date(2005, 3, 10, 12, 10, 4, 0, time.UTC)
+	start := time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	err := repository.UpsertDataStreams(ctx, []*model.DataStreams{
+		{Nmi: "TST0000001", NmiConfiguration: "E1E2", NmiSuffix: "E2", Uom: "kWh", IntervalLength: 30},
+		{Nmi: "TST0000001", NmiConfiguration: "E1E2", NmiSuffix: "E1", Uom: "kWh", IntervalLength: 30},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	// the latest details of a data stream replace the stored ones
+	err = repository.UpsertDataStreams(ctx, []*model.DataStreams{
+		{Nmi: "TST0000001", NmiConfiguration: "E1E2", NmiSuffix: "E1", Uom: "kWh", IntervalLength: 15},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	dataStreams, err := repository.DataStreamsByNmi(ctx, "TST0000001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(dataStreams) != 2 || dataStreams[0].NmiSuffix != "E1" || dataStreams[0].IntervalLength != 15 || dataStreams[1].NmiSuffix != "E2" {
+		t.Errorf("Expected the data streams E1 and E2 of TST0000001, got %+v instead", dataStreams)
+	}
+
+	intervalReading := func(interval int, value string, updated time.Time) *model.IntervalReadings {
+		return &model.IntervalReadings{
+			Nmi:            "TST0000001",
+			NmiSuffix:      "E1",
+			IntervalStart:  start.Add(time.Duration(interval) * 30 * time.Minute),
+			Value:          decimal.MustParse(value),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			Quality:        "A",
+			UpdateDateTime: updated,
+		}
+	}
+	result, err := repository.UpsertIntervalReadings(ctx, []*model.IntervalReadings{
+		intervalReading(1, "0.5", updateDateTime),
+		intervalReading(0, "0.25", updateDateTime),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 2}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	// a revised interval reading replaces the stored one, while an older one is skipped
+	result, err = repository.UpsertIntervalReadings(ctx, []*model.IntervalReadings{
+		intervalReading(0, "0.75", updateDateTime.Add(time.Hour)),
+		intervalReading(1, "1.5", updateDateTime.Add(-time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Updated: 1, Skipped: 1}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	intervalReadings, err := repository.IntervalReadingsByNmi(ctx, "TST0000001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(intervalReadings) != 2 || intervalReadings[0].Value != decimal.MustParse("0.75") || intervalReadings[1].Value != decimal.MustParse("0.5") {
+		t.Errorf("Expected the interval readings 0.75 and 0.5, got %+v instead", intervalReadings)
+	}
+
+	readDateTime := time.Date(2005, 3, 1, 10, 30, 0, 0, time.UTC)
+	b2bDetails := []*model.B2bDetails{
+		{Nmi: "TST0000001", NmiSuffix: "E1", TransCode: "S", RetServiceOrder: "RETNSRVCEORD2", ReadDateTime: &readDateTime},
+		{Nmi: "TST0000001", NmiSuffix: "E1", TransCode: "A", RetServiceOrder: "RETNSRVCEORD1"},
+	}
+	result, err = repository.InsertB2bDetails(ctx, b2bDetails)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 2}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	// B2B details that are already stored are skipped, with or without a read date time
+	result, err = repository.InsertB2bDetails(ctx, b2bDetails)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Skipped: 2}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	b2bDetails, err = repository.B2bDetailsByNmi(ctx, "TST0000001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(b2bDetails) != 2 || b2bDetails[0].RetServiceOrder != "RETNSRVCEORD1" || b2bDetails[1].RetServiceOrder != "RETNSRVCEORD2" {
+		t.Errorf("Expected the B2B details of RETNSRVCEORD1 and RETNSRVCEORD2, got %+v instead", b2bDetails)
+	}
+
+	accumulationReading := func(quantity string, updated time.Time) *model.AccumulationReadings {
+		return &model.AccumulationReadings{
+			Nmi:                          "TST0000001",
+			NmiConfiguration:             "11",
+			RegisterID:                   "1",
+			NmiSuffix:                    "11",
+			MeterSerialNumber:            "METSER123",
+			DirectionIndicator:           "E",
+			PreviousRegisterRead:         decimal.MustParse("1000"),
+			PreviousRegisterReadDateTime: start,
+			PreviousQualityMethod:        "A",
+			CurrentRegisterRead:          decimal.MustParse("1250"),
+			CurrentRegisterReadDateTime:  start.AddDate(0, 1, 0),
+			CurrentQualityMethod:         "A",
+			Quantity:                     decimal.MustParse(quantity),
+			Uom:                          "kWh",
+			OriginalUom:                  "kWh",
+			UpdateDateTime:               updated,
+		}
+	}
+	result, err = repository.UpsertAccumulationReadings(ctx, []*model.AccumulationReadings{accumulationReading("250", updateDateTime)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 1}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	result, err = repository.UpsertAccumulationReadings(ctx, []*model.AccumulationReadings{accumulationReading("300", updateDateTime)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Skipped: 1}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+	accumulationReadings, err := repository.AccumulationReadingsByNmi(ctx, "TST0000001")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if len(accumulationReadings) != 1 || accumulationReadings[0].Quantity != decimal.MustParse("250") {
+		t.Errorf("Expected the accumulation reading of 250, got %+v instead", accumulationReadings)
+	}
+}
+
+// deleteTestNmiBlocks deletes the models of the NMI blocks created by testNmiBlockRepository.
+func deleteTestNmiBlocks(tb testing.TB, db *sql.DB) {
+	tb.Helper()
+	for _, tableName := range []string{"data_streams", "interval_readings", "b2b_details", "accumulation_readings"} {
+		_, err := db.Exec("DELETE FROM " + tableName + " WHERE nmi LIKE 'TST%'")
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
@@ -1,24 +1,387 @@
+// Package repo stores the models of NMI files in Postgres through repositories, which also have in-memory versions
+// for tests that run without a database, and bulk upserts readings in chunks with the precedence of their UpdateDateTime.
 package repo
 
 import (
+	"context"
 	sql "database/sql"
-	// "fmt"
+	"fmt"
+	"strings"
+	"time"
 
+	postgres "github.com/go-jet/jet/v2/postgres"
 	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
 	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
 )
 
-// BulkInsertMeterReadings takes in a list of MeterReadings and inserts them to the database as a single bulk insert.
-// It assumes that the insert should happen if and only if there are no conflicts.
-func BulkInsertMeterReadings(db *sql.DB, readings []*model.MeterReadings) error {
+// MaxBindParameters is the largest number of bind parameters that Postgres accepts in a single statement.
+const MaxBindParameters = 65535
 
-	insertStmt := table.MeterReadings.
-		INSERT(table.MeterReadings.Nmi, table.MeterReadings.Timestamp, table.MeterReadings.Consumption).
-		MODELS(readings).
-		ON_CONFLICT(table.MeterReadings.ID).DO_NOTHING()
+// TxMode decides how the chunks of a bulk insert are grouped into transactions.
+type TxMode int
 
-	// debugSQL := insertStmt.DebugSql()
-	// fmt.Println(debugSQL)
-	_, err := insertStmt.Exec(db)
+const (
+	// SingleTransaction inserts every chunk in one transaction, so that a failed chunk rolls back the whole bulk insert.
+	SingleTransaction TxMode = iota
+	// TransactionPerChunk commits every chunk in its own transaction, so that the chunks before a failed chunk are kept.
+	TransactionPerChunk
+)
+
+// InsertOptions configures how a bulk insert is split into chunks.
+type InsertOptions struct {
+	// ChunkSize is the number of rows inserted by one statement. It defaults to, and is limited to,
+	// the number of rows whose columns fit within MaxBindParameters.
+	ChunkSize int
+	TxMode    TxMode
+	// TxOptions are passed to every transaction that is started.
+	TxOptions *sql.TxOptions
+}
+
+// ChunkError is returned when a chunk of a bulk insert fails, and lists the NMIs of the rows in the chunk.
+type ChunkError struct {
+	Table string
+	// Chunk is the index of the chunk that failed, and Rows is the number of rows in it.
+	Chunk int
+	Rows  int
+	Nmis  []string
+	// Committed is the number of chunks before Chunk that were committed, which is always 0 for SingleTransaction.
+	Committed int
+	Err       error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("insert into %s failed for chunk %d of %d rows with NMIs %s: %v", e.Table, e.Chunk, e.Rows, strings.Join(e.Nmis, ", "), e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// UpsertResult counts what happened to the rows of a bulk upsert.
+// A row is Skipped when a row with the same natural key has an UpdateDateTime that is not older,
+// either in the database or later in the same bulk upsert.
+type UpsertResult struct {
+	Inserted int64
+	Updated  int64
+	Skipped  int64
+}
+
+// Add returns the sum of r and other.
+func (r UpsertResult) Add(other UpsertResult) UpsertResult {
+	return UpsertResult{Inserted: r.Inserted + other.Inserted, Updated: r.Updated + other.Updated, Skipped: r.Skipped + other.Skipped}
+}
+
+// BulkUpsertMeterReadings takes in a list of MeterReadings and upserts them to the database as chunked bulk inserts.
+// A meter reading is identified by its NMI, NMISuffix and timestamp, and replaces the stored meter reading
+// only when its UpdateDateTime is later.
+func BulkUpsertMeterReadings(ctx context.Context, db *sql.DB, readings []*model.MeterReadings, opts InsertOptions) (UpsertResult, error) {
+	uniqueReadings := latestRows(readings, meterReadingNaturalKey, meterReadingUpdateDateTime)
+
+	columns := len(table.MeterReadings.MutableColumns)
+	result, err := insertChunks(ctx, db, opts, table.MeterReadings.TableName(), columns, uniqueReadings, meterReadingNmi,
+		func(tx *sql.Tx, chunk []*model.MeterReadings) (UpsertResult, error) {
+			insertStmt := table.MeterReadings.
+				INSERT(table.MeterReadings.MutableColumns).
+				MODELS(chunk).
+				ON_CONFLICT(table.MeterReadings.Nmi, table.MeterReadings.NmiSuffix, table.MeterReadings.Timestamp).
+				DO_UPDATE(postgres.SET(
+					table.MeterReadings.MutableColumns.SET(row(table.MeterReadings.EXCLUDED.MutableColumns)),
+				).WHERE(table.MeterReadings.UpdateDateTime.LT(table.MeterReadings.EXCLUDED.UpdateDateTime))).
+				RETURNING(insertedRow)
+
+			// debugSQL := insertStmt.DebugSql()
+			// fmt.Println(debugSQL)
+			return upsertRows(ctx, tx, insertStmt, len(chunk))
+		})
+	result.Skipped += int64(len(readings) - len(uniqueReadings))
+	return result, err
+}
+
+// BulkUpsertIntervalReadings takes in a list of IntervalReadings and upserts them to the database as chunked bulk inserts.
+// An interval reading is identified by its NMI, NMISuffix and interval start, and replaces the stored interval reading
+// only when its UpdateDateTime is later.
+func BulkUpsertIntervalReadings(ctx context.Context, db *sql.DB, readings []*model.IntervalReadings, opts InsertOptions) (UpsertResult, error) {
+	uniqueReadings := latestRows(readings, intervalReadingNaturalKey, intervalReadingUpdateDateTime)
+
+	columns := len(table.IntervalReadings.MutableColumns)
+	result, err := insertChunks(ctx, db, opts, table.IntervalReadings.TableName(), columns, uniqueReadings, intervalReadingNmi,
+		func(tx *sql.Tx, chunk []*model.IntervalReadings) (UpsertResult, error) {
+			insertStmt := table.IntervalReadings.
+				INSERT(table.IntervalReadings.MutableColumns).
+				MODELS(chunk).
+				ON_CONFLICT(table.IntervalReadings.Nmi, table.IntervalReadings.NmiSuffix, table.IntervalReadings.IntervalStart).
+				DO_UPDATE(postgres.SET(
+					table.IntervalReadings.MutableColumns.SET(row(table.IntervalReadings.EXCLUDED.MutableColumns)),
+				).WHERE(table.IntervalReadings.UpdateDateTime.LT(table.IntervalReadings.EXCLUDED.UpdateDateTime))).
+				RETURNING(insertedRow)
+
+			return upsertRows(ctx, tx, insertStmt, len(chunk))
+		})
+	result.Skipped += int64(len(readings) - len(uniqueReadings))
+	return result, err
+}
+
+// BulkInsertB2bDetails takes in a list of B2bDetails and inserts them to the database as chunked bulk inserts.
+// A B2B detail is identified by its NMI, NMISuffix, retailer service order and read date time,
+// and a B2B detail that is already stored is skipped, so that a file that is loaded again does not duplicate them.
+// The B2B details that were inserted and skipped are counted, as they are never updated.
+func BulkInsertB2bDetails(ctx context.Context, db *sql.DB, b2bDetails []*model.B2bDetails, opts InsertOptions) (UpsertResult, error) {
+	columns := len(table.B2bDetails.MutableColumns)
+	return insertChunks(ctx, db, opts, table.B2bDetails.TableName(), columns, b2bDetails, b2bDetailsNmi,
+		func(tx *sql.Tx, chunk []*model.B2bDetails) (UpsertResult, error) {
+			insertStmt := table.B2bDetails.
+				INSERT(table.B2bDetails.MutableColumns).
+				MODELS(chunk).
+				// the unique index is on an expression, which any conflict target of DO NOTHING matches
+				ON_CONFLICT().DO_NOTHING()
+
+			result, err := insertStmt.ExecContext(ctx, tx)
+			if err != nil {
+				return UpsertResult{}, err
+			}
+			inserted, err := result.RowsAffected()
+			if err != nil {
+				return UpsertResult{}, err
+			}
+			return UpsertResult{Inserted: inserted, Skipped: int64(len(chunk)) - inserted}, nil
+		})
+}
+
+// BulkUpsertDataStreams takes in a list of DataStreams and upserts them to the database as chunked bulk inserts.
+// A data stream is identified by its NMI and NMISuffix, and the latest details of a data stream replace the existing ones.
+func BulkUpsertDataStreams(ctx context.Context, db *sql.DB, dataStreams []*model.DataStreams, opts InsertOptions) error {
+	if len(dataStreams) == 0 {
+		return nil
+	}
+	// a data stream can appear in more than one NMI 200 block, but can only be upserted once per statement
+	latest := map[[2]string]int{}
+	uniqueDataStreams := []*model.DataStreams{}
+	for _, dataStream := range dataStreams {
+		key := [2]string{dataStream.Nmi, dataStream.NmiSuffix}
+		if i, ok := latest[key]; ok {
+			uniqueDataStreams[i] = dataStream
+			continue
+		}
+		latest[key] = len(uniqueDataStreams)
+		uniqueDataStreams = append(uniqueDataStreams, dataStream)
+	}
+
+	columns := len(table.DataStreams.MutableColumns)
+	_, err := insertChunks(ctx, db, opts, table.DataStreams.TableName(), columns, uniqueDataStreams, dataStreamNmi,
+		func(tx *sql.Tx, chunk []*model.DataStreams) (UpsertResult, error) {
+			insertStmt := table.DataStreams.
+				INSERT(table.DataStreams.MutableColumns).
+				MODELS(chunk).
+				ON_CONFLICT(table.DataStreams.Nmi, table.DataStreams.NmiSuffix).
+				DO_UPDATE(postgres.SET(
+					table.DataStreams.MutableColumns.SET(row(table.DataStreams.EXCLUDED.MutableColumns)),
+				))
+
+			_, err := insertStmt.ExecContext(ctx, tx)
+			return UpsertResult{}, err
+		})
 	return err
 }
+
+// InsertFileHeader takes in the FileHeaders of an NMI file and inserts it to the database.
+// The ID generated by the database is set on the FileHeaders.
+func InsertFileHeader(ctx context.Context, db *sql.DB, header *model.FileHeaders) error {
+
+	insertStmt := table.FileHeaders.
+		INSERT(table.FileHeaders.MutableColumns).
+		MODEL(header).
+		RETURNING(table.FileHeaders.ID)
+
+	return insertStmt.QueryContext(ctx, db, header)
+}
+
+// BulkUpsertAccumulationReadings takes in a list of AccumulationReadings and upserts them to the database as chunked bulk inserts.
+// An accumulation reading is identified by its NMI, NMISuffix and current register read date time, and replaces
+// the stored accumulation reading only when its UpdateDateTime is later.
+func BulkUpsertAccumulationReadings(ctx context.Context, db *sql.DB, readings []*model.AccumulationReadings, opts InsertOptions) (UpsertResult, error) {
+	uniqueReadings := latestRows(readings, accumulationReadingNaturalKey, accumulationReadingUpdateDateTime)
+
+	columns := len(table.AccumulationReadings.MutableColumns)
+	result, err := insertChunks(ctx, db, opts, table.AccumulationReadings.TableName(), columns, uniqueReadings, accumulationReadingNmi,
+		func(tx *sql.Tx, chunk []*model.AccumulationReadings) (UpsertResult, error) {
+			insertStmt := table.AccumulationReadings.
+				INSERT(table.AccumulationReadings.MutableColumns).
+				MODELS(chunk).
+				ON_CONFLICT(table.AccumulationReadings.Nmi, table.AccumulationReadings.NmiSuffix, table.AccumulationReadings.CurrentRegisterReadDateTime).
+				DO_UPDATE(postgres.SET(
+					table.AccumulationReadings.MutableColumns.SET(row(table.AccumulationReadings.EXCLUDED.MutableColumns)),
+				).WHERE(table.AccumulationReadings.UpdateDateTime.LT(table.AccumulationReadings.EXCLUDED.UpdateDateTime))).
+				RETURNING(insertedRow)
+
+			return upsertRows(ctx, tx, insertStmt, len(chunk))
+		})
+	result.Skipped += int64(len(readings) - len(uniqueReadings))
+	return result, err
+}
+
+// row creates a ROW expression out of a list of columns, so that the columns can be assigned in a single SET.
+func row(columns postgres.ColumnList) postgres.Expression {
+	expressions := make([]postgres.Expression, 0, len(columns))
+	for _, column := range columns {
+		expressions = append(expressions, column)
+	}
+	return postgres.ROW(expressions...)
+}
+
+// chunkSize returns the number of rows with the given number of columns that are inserted by one statement.
+func (o InsertOptions) chunkSize(columns int) int {
+	maxRows := MaxBindParameters / columns
+	if o.ChunkSize < 1 || o.ChunkSize > maxRows {
+		return maxRows
+	}
+	return o.ChunkSize
+}
+
+// insertChunks splits rows into chunks, and calls insert for every chunk in the transactions of the TxMode.
+// The first chunk that fails stops the bulk insert, and is returned as a ChunkError.
+// The UpsertResult of a chunk is only counted once its transaction is committed.
+func insertChunks[T any](ctx context.Context, db *sql.DB, opts InsertOptions, tableName string, columns int, rows []T, nmi func(T) string, insert func(tx *sql.Tx, chunk []T) (UpsertResult, error)) (UpsertResult, error) {
+	var result, uncommitted UpsertResult
+	if len(rows) == 0 {
+		return result, nil
+	}
+	size := opts.chunkSize(columns)
+	chunkError := func(index int, chunk []T, err error) error {
+		committed := 0
+		if opts.TxMode == TransactionPerChunk {
+			committed = index
+		}
+		return &ChunkError{Table: tableName, Chunk: index, Rows: len(chunk), Nmis: chunkNmis(chunk, nmi), Committed: committed, Err: err}
+	}
+
+	var tx *sql.Tx
+	var err error
+	for index, start := 0, 0; start < len(rows); index, start = index+1, start+size {
+		chunk := rows[start:min(start+size, len(rows))]
+		if tx == nil {
+			tx, err = db.BeginTx(ctx, opts.TxOptions)
+			if err != nil {
+				return result, chunkError(index, chunk, err)
+			}
+		}
+		chunkResult, err := insert(tx, chunk)
+		if err != nil {
+			// the error of the insert is more useful than the error of the rollback
+			_ = tx.Rollback()
+			return result, chunkError(index, chunk, err)
+		}
+		uncommitted = uncommitted.Add(chunkResult)
+		if opts.TxMode == TransactionPerChunk {
+			err = tx.Commit()
+			tx = nil
+			if err != nil {
+				return result, chunkError(index, chunk, err)
+			}
+			result, uncommitted = result.Add(uncommitted), UpsertResult{}
+		}
+	}
+	if tx != nil {
+		// every chunk is lost when a SingleTransaction fails to commit, so the error lists the NMIs of every row
+		err = tx.Commit()
+		if err != nil {
+			return result, chunkError(0, rows, err)
+		}
+		result = result.Add(uncommitted)
+	}
+	return result, nil
+}
+
+// insertedRow is returned for every row of an upsert. xmax is only 0 for a row version that was inserted,
+// while a row version that was updated has the ID of the transaction that locked the replaced version.
+var insertedRow = postgres.RawBool("xmax = 0").AS("inserted")
+
+// upsertRows runs an upsert that returns insertedRow, and counts the rows that were inserted and updated.
+// The rows that are not returned were skipped by the WHERE condition of the conflict action.
+func upsertRows(ctx context.Context, tx *sql.Tx, insertStmt postgres.InsertStatement, rows int) (UpsertResult, error) {
+	query, args := insertStmt.Sql()
+	returned, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	defer returned.Close()
+
+	result := UpsertResult{}
+	for returned.Next() {
+		var inserted bool
+		err = returned.Scan(&inserted)
+		if err != nil {
+			return UpsertResult{}, err
+		}
+		if inserted {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+	}
+	if err = returned.Err(); err != nil {
+		return UpsertResult{}, err
+	}
+	result.Skipped = int64(rows) - result.Inserted - result.Updated
+	return result, nil
+}
+
+// meterReadingKey is the natural key of meter, interval and accumulation readings: a NMI, NMISuffix and time.
+type meterReadingKey struct {
+	nmi       string
+	nmiSuffix string
+	time      time.Time
+}
+
+func meterReadingNaturalKey(reading *model.MeterReadings) meterReadingKey {
+	return meterReadingKey{reading.Nmi, reading.NmiSuffix, reading.Timestamp}
+}
+
+// latestRows removes the rows with the same key as a row with a later, or the same, UpdateDateTime,
+// as an upsert cannot update the same row twice in one statement. The order of the rows that are kept is unchanged.
+func latestRows[T any](rows []T, key func(T) meterReadingKey, updateDateTime func(T) time.Time) []T {
+	latest := map[meterReadingKey]int{}
+	unique := make([]T, 0, len(rows))
+	for _, row := range rows {
+		k := key(row)
+		if i, ok := latest[k]; ok {
+			if !updateDateTime(row).Before(updateDateTime(unique[i])) {
+				unique[i] = row
+			}
+			continue
+		}
+		latest[k] = len(unique)
+		unique = append(unique, row)
+	}
+	return unique
+}
+
+// chunkNmis returns the distinct NMIs of a chunk, in the order that they first appear.
+func chunkNmis[T any](chunk []T, nmi func(T) string) []string {
+	seen := map[string]bool{}
+	nmis := []string{}
+	for _, row := range chunk {
+		if !seen[nmi(row)] {
+			seen[nmi(row)] = true
+			nmis = append(nmis, nmi(row))
+		}
+	}
+	return nmis
+}
+
+func meterReadingNmi(reading *model.MeterReadings) string               { return reading.Nmi }
+func intervalReadingNmi(reading *model.IntervalReadings) string         { return reading.Nmi }
+func b2bDetailsNmi(b2bDetails *model.B2bDetails) string                 { return b2bDetails.Nmi }
+func dataStreamNmi(dataStream *model.DataStreams) string                { return dataStream.Nmi }
+func accumulationReadingNmi(reading *model.AccumulationReadings) string { return reading.Nmi }
+
+func meterReadingUpdateDateTime(reading *model.MeterReadings) time.Time {
+	return reading.UpdateDateTime
+}
+
+func intervalReadingUpdateDateTime(reading *model.IntervalReadings) time.Time {
+	return reading.UpdateDateTime
+}
+
+func accumulationReadingUpdateDateTime(reading *model.AccumulationReadings) time.Time {
+	return reading.UpdateDateTime
+}
@@ -0,0 +1,464 @@
+package repo_test
+
+import (
+	"context"
+	sql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/ts33/energy-reading/.gen/postgres/public/model"
+	table "github.com/ts33/energy-reading/.gen/postgres/public/table"
+	"github.com/ts33/energy-reading/decimal"
+	repo "github.com/ts33/energy-reading/repository"
+
+	_ "github.com/lib/pq"
+)
+
+// testDSN is the database of docker-compose.yml, which can be replaced with the TEST_DATABASE_URL environment variable.
+const testDSN = "host=localhost port=5432 user=test123 password=test123 dbname=postgres sslmode=disable"
+
+type BulkUpsertChunksTestCase struct {
+	Name      string
+	Readings  int
+	Opts      repo.InsertOptions
+	FailOn    int
+	Events    []string
+	Result    repo.UpsertResult
+	Err       error
+	ChunkNmis []string
+}
+
+func TestBulkUpsertMeterReadingsChunks(t *testing.T) {
+	columns := len(table.MeterReadings.MutableColumns)
+	query := func(rows int) string { return fmt.Sprintf("query %d", rows*columns) }
+
+	tests := []BulkUpsertChunksTestCase{
+		{
+			Name:     "Happy Case - no readings",
+			Readings: 0,
+			Opts:     repo.InsertOptions{ChunkSize: 2},
+			Events:   nil,
+		},
+		{
+			Name:     "Happy Case - chunks in a single transaction",
+			Readings: 5,
+			Opts:     repo.InsertOptions{ChunkSize: 2},
+			Events:   []string{"begin", query(2), query(2), query(1), "commit"},
+			Result:   repo.UpsertResult{Inserted: 5},
+		},
+		{
+			Name:     "Happy Case - a transaction per chunk",
+			Readings: 5,
+			Opts:     repo.InsertOptions{ChunkSize: 2, TxMode: repo.TransactionPerChunk},
+			Events:   []string{"begin", query(2), "commit", "begin", query(2), "commit", "begin", query(1), "commit"},
+			Result:   repo.UpsertResult{Inserted: 5},
+		},
+		{
+			Name:     "Happy Case - transaction options",
+			Readings: 1,
+			Opts:     repo.InsertOptions{TxOptions: &sql.TxOptions{Isolation: sql.LevelSerializable}},
+			Events:   []string{"begin Serializable", query(1), "commit"},
+			Result:   repo.UpsertResult{Inserted: 1},
+		},
+		{
+			Name:      "Error Case - failed chunk rolls back the single transaction",
+			Readings:  5,
+			Opts:      repo.InsertOptions{ChunkSize: 2},
+			FailOn:    2,
+			Events:    []string{"begin", query(2), query(2), "rollback"},
+			Err:       errors.New("insert into meter_readings failed for chunk 1 of 2 rows with NMIs TST0000002, TST0000003: connection reset"),
+			ChunkNmis: []string{"TST0000002", "TST0000003"},
+		},
+		{
+			Name:      "Error Case - failed chunk keeps the chunks that were committed",
+			Readings:  5,
+			Opts:      repo.InsertOptions{ChunkSize: 2, TxMode: repo.TransactionPerChunk},
+			FailOn:    3,
+			Events:    []string{"begin", query(2), "commit", "begin", query(2), "commit", "begin", query(1), "rollback"},
+			Result:    repo.UpsertResult{Inserted: 4},
+			Err:       errors.New("insert into meter_readings failed for chunk 2 of 1 rows with NMIs TST0000004: connection reset"),
+			ChunkNmis: []string{"TST0000004"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			recorder := &recordingConnector{failOn: tt.FailOn}
+			db := sql.OpenDB(recorder)
+			defer db.Close()
+
+			result, err := repo.BulkUpsertMeterReadings(context.Background(), db, buildMeterReadings(tt.Readings), tt.Opts)
+
+			// assert that errors are raised
+			if err != nil {
+				if tt.Err == nil {
+					t.Errorf("Expected no error, got %v instead", err)
+				} else if tt.Err.Error() != err.Error() {
+					t.Errorf("Expected err %v, got %v instead", tt.Err, err)
+				}
+				var chunkErr *repo.ChunkError
+				if !errors.As(err, &chunkErr) || !reflect.DeepEqual(chunkErr.Nmis, tt.ChunkNmis) {
+					t.Errorf("Expected a ChunkError with NMIs %v, got %#v instead", tt.ChunkNmis, err)
+				}
+			} else if tt.Err != nil {
+				t.Errorf("Expected err %v, got no error instead", tt.Err)
+			}
+
+			if reflect.DeepEqual(tt.Events, recorder.events) != true {
+				t.Errorf("Expected %v, got %v instead", tt.Events, recorder.events)
+			}
+			if result != tt.Result {
+				t.Errorf("Expected %+v, got %+v instead", tt.Result, result)
+			}
+		})
+	}
+}
+
+func TestBulkUpsertMeterReadingsPrecedence(t *testing.T) {
+	readings := buildMeterReadings(4)
+	// a revised reading for the first NMI, which replaces the reading before it
+	revised := *readings[0]
+	revised.Consumption = decimal.MustParse("2.5")
+	revised.UpdateDateTime = readings[0].UpdateDateTime.Add(time.Hour)
+	// an older reading for the second NMI, which is replaced by the reading before it
+	older := *readings[1]
+	older.UpdateDateTime = readings[1].UpdateDateTime.Add(-time.Hour)
+	readings = append(readings, &revised, &older)
+
+	// the database inserts the first NMI, updates the second, and skips the third as it is not newer
+	recorder := &recordingConnector{returning: []bool{true, false, true}}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	result, err := repo.BulkUpsertMeterReadings(context.Background(), db, readings, repo.InsertOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	expected := repo.UpsertResult{Inserted: 2, Updated: 1, Skipped: 3}
+	if result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+
+	// one row is sent per natural key, with the latest UpdateDateTime
+	columns := len(table.MeterReadings.MutableColumns)
+	if len(recorder.values) != 4*columns {
+		t.Fatalf("Expected %v values, got %v instead", 4*columns, len(recorder.values))
+	}
+	if recorder.values[3] != "2.5" || recorder.values[columns+9] != readings[1].UpdateDateTime {
+		t.Errorf("Expected the latest readings to be sent, got %v instead", recorder.values)
+	}
+	for _, clause := range []string{
+		"ON CONFLICT (nmi, nmi_suffix, timestamp) DO UPDATE",
+		"WHERE meter_readings.update_date_time < excluded.update_date_time",
+		"RETURNING (xmax = 0) AS \"inserted\"",
+	} {
+		if !strings.Contains(recorder.query, clause) {
+			t.Errorf("Expected the upsert to contain %v, got %v instead", clause, recorder.query)
+		}
+	}
+}
+
+func TestBulkInsertB2bDetailsSkipsStoredDetails(t *testing.T) {
+	// one of the B2B details is already stored, so the insert affects a single row
+	rowsAffected := int64(1)
+	recorder := &recordingConnector{rowsAffected: &rowsAffected}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	readDateTime := time.Date(2005, 3, 1, 10, 30, 0, 0, time.UTC)
+	b2bDetails := []*model.B2bDetails{
+		{Nmi: "NEM1000001", NmiSuffix: "E1", TransCode: "S", RetServiceOrder: "RETNSRVCEORD1", ReadDateTime: &readDateTime},
+		{Nmi: "NEM1000001", NmiSuffix: "E1", TransCode: "A", RetServiceOrder: "RETNSRVCEORD2"},
+	}
+	result, err := repo.BulkInsertB2bDetails(context.Background(), db, b2bDetails, repo.InsertOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if !strings.Contains(recorder.query, "ON CONFLICT DO NOTHING") {
+		t.Errorf("Expected the insert to skip stored B2B details, got %v instead", recorder.query)
+	}
+	expected := repo.UpsertResult{Inserted: 1, Skipped: 1}
+	if result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+}
+
+func TestBulkUpsertIntervalReadingsRespectsParameterLimit(t *testing.T) {
+	recorder := &recordingConnector{}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	// a month of 5 minute intervals for a single data stream
+	readings := make([]*model.IntervalReadings, 0, 31*288)
+	for i := 0; i < cap(readings); i++ {
+		readings = append(readings, &model.IntervalReadings{
+			Nmi:            "NEM1000001",
+			NmiSuffix:      "E1",
+			IntervalStart:  time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * 5 * time.Minute),
+			Value:          decimal.MustParse("0.125"),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			Quality:        "A",
+			UpdateDateTime: time.Date(2005, 3, 10, 12, 10, 4, 0, time.UTC),
+		})
+	}
+
+	// a chunk size above the limit is lowered to it
+	result, err := repo.BulkUpsertIntervalReadings(context.Background(), db, readings, repo.InsertOptions{ChunkSize: len(readings)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	rows := 0
+	for _, args := range recorder.args {
+		if args > repo.MaxBindParameters {
+			t.Errorf("Expected at most %v parameters, got %v instead", repo.MaxBindParameters, args)
+		}
+		rows += args / len(table.IntervalReadings.MutableColumns)
+	}
+	if len(recorder.args) < 2 || rows != len(readings) || result.Inserted != int64(len(readings)) {
+		t.Errorf("Expected %v rows over several statements, got %v rows in %v statements instead", len(readings), rows, len(recorder.args))
+	}
+}
+
+func TestCopyMeterReadings(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	readings := buildMeterReadings(100)
+
+	result, err := repo.CopyMeterReadings(ctx, db, readings, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 100}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+
+	// readings that are already stored are skipped, unless they are newer
+	readings = buildMeterReadings(150)
+	readings[0].Consumption = decimal.MustParse("2.5")
+	readings[0].UpdateDateTime = readings[0].UpdateDateTime.Add(time.Hour)
+	result, err = repo.CopyMeterReadings(ctx, db, readings, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v instead", err)
+	}
+	if expected := (repo.UpsertResult{Inserted: 50, Updated: 1, Skipped: 99}); result != expected {
+		t.Errorf("Expected %+v, got %+v instead", expected, result)
+	}
+
+	var consumption decimal.Decimal
+	err = db.QueryRowContext(ctx, "SELECT consumption FROM meter_readings WHERE nmi = $1", readings[0].Nmi).Scan(&consumption)
+	if err != nil || consumption != readings[0].Consumption {
+		t.Errorf("Expected consumption %v, got %v, %v instead", readings[0].Consumption, consumption, err)
+	}
+}
+
+// benchmark loading 50000 meter readings, which needs several chunks of inserts
+func BenchmarkBulkUpsertMeterReadings(b *testing.B) {
+	db := openTestDB(b)
+	readings := buildMeterReadings(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := repo.BulkUpsertMeterReadings(context.Background(), db, readings, repo.InsertOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		deleteTestMeterReadings(b, db)
+		b.StartTimer()
+	}
+}
+
+// benchmark loading 50000 meter readings through a staging table
+func BenchmarkCopyMeterReadings(b *testing.B) {
+	db := openTestDB(b)
+	readings := buildMeterReadings(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := repo.CopyMeterReadings(context.Background(), db, readings, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		deleteTestMeterReadings(b, db)
+		b.StartTimer()
+	}
+}
+
+// openTestDB connects to the test database, and skips the test when it is not running.
+// The meter readings created by buildMeterReadings are deleted before and after the test.
+func openTestDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dsn = testDSN
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		tb.Skipf("Skipping as the test database is not available: %v", err)
+	}
+	deleteTestMeterReadings(tb, db)
+	tb.Cleanup(func() {
+		deleteTestMeterReadings(tb, db)
+		db.Close()
+	})
+	return db
+}
+
+// deleteTestMeterReadings deletes the meter readings created by buildMeterReadings.
+func deleteTestMeterReadings(tb testing.TB, db *sql.DB) {
+	tb.Helper()
+	_, err := db.Exec("DELETE FROM meter_readings WHERE nmi LIKE 'TST%'")
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// buildMeterReadings creates count meter readings, each for a different test NMI.
+func buildMeterReadings(count int) []*model.MeterReadings {
+	readings := make([]*model.MeterReadings, 0, count)
+	for i := 0; i < count; i++ {
+		readings = append(readings, &model.MeterReadings{
+			Nmi:            fmt.Sprintf("TST%07d", i),
+			NmiSuffix:      "E1",
+			Timestamp:      time.Date(2005, 3, 1, 0, 0, 0, 0, time.UTC),
+			Consumption:    decimal.MustParse("1.5"),
+			Uom:            "kWh",
+			OriginalUom:    "kWh",
+			QualityMethod:  "A",
+			UpdateDateTime: time.Date(2005, 3, 10, 12, 10, 4, 0, time.UTC),
+		})
+	}
+	return readings
+}
+
+// recordingConnector is a database/sql driver that records the transactions and statements that it receives,
+// and fails the statement numbered failOn, counting from 1.
+// A query returns the rows of returning as the inserted column, or returns every row as inserted when it is nil.
+// An exec affects rowsAffected rows, or a row per argument when it is nil.
+type recordingConnector struct {
+	events       []string
+	args         []int
+	query        string
+	values       []any
+	execs        int
+	failOn       int
+	returning    []bool
+	rowsAffected *int64
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recordingConn struct {
+	connector *recordingConnector
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	event := "begin"
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		event += " " + sql.IsolationLevel(opts.Isolation).String()
+	}
+	c.connector.events = append(c.connector.events, event)
+	return c, nil
+}
+
+func (c *recordingConn) Commit() error {
+	c.connector.events = append(c.connector.events, "commit")
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	c.connector.events = append(c.connector.events, "rollback")
+	return nil
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	err := c.record("exec", query, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.connector.rowsAffected != nil {
+		return driver.RowsAffected(*c.connector.rowsAffected), nil
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	err := c.record("query", query, args)
+	if err != nil {
+		return nil, err
+	}
+	returning := c.connector.returning
+	if returning == nil {
+		// every row of the statement is returned as inserted
+		columns := strings.Count(query[:strings.Index(query, ")")], ",") + 1
+		returning = make([]bool, len(args)/columns)
+		for i := range returning {
+			returning[i] = true
+		}
+	}
+	return &recordingRows{returning: returning}, nil
+}
+
+func (c *recordingConn) record(event string, query string, args []driver.NamedValue) error {
+	c.connector.execs++
+	c.connector.events = append(c.connector.events, fmt.Sprintf("%s %d", event, len(args)))
+	c.connector.args = append(c.connector.args, len(args))
+	c.connector.query = query
+	c.connector.values = c.connector.values[:0]
+	for _, arg := range args {
+		c.connector.values = append(c.connector.values, arg.Value)
+	}
+	if c.connector.execs == c.connector.failOn {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+type recordingRows struct {
+	returning []bool
+}
+
+func (r *recordingRows) Columns() []string {
+	return []string{"inserted"}
+}
+
+func (r *recordingRows) Close() error {
+	return nil
+}
+
+func (r *recordingRows) Next(dest []driver.Value) error {
+	if len(r.returning) == 0 {
+		return io.EOF
+	}
+	dest[0], r.returning = r.returning[0], r.returning[1:]
+	return nil
+}